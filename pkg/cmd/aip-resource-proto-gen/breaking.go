@@ -0,0 +1,451 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/spf13/cobra"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+const (
+	// breakingWire marks the changes breaking the clients on the wire, either
+	// the binary or the JSON/HTTP encoding.
+	breakingWire = "wire"
+	// breakingSource marks the changes breaking the code of the clients or the
+	// contract of the API, while keeping the wire format.
+	breakingSource = "source"
+)
+
+// breakingChange is an incompatibility between two versions of a proto file.
+type breakingChange struct {
+	Kind string
+	// Element is the full name of the changed element.
+	Element string
+	Message string
+}
+
+func (c breakingChange) String() string {
+	return fmt.Sprintf("%s: %s: %s", c.Kind, c.Element, c.Message)
+}
+
+func newBreakingCommand(cfg *Config) *cobra.Command {
+	var against string
+
+	cmd := &cobra.Command{
+		Use:   "breaking <resource>",
+		Short: "Report the breaking changes of the generated protobuf IDL file against a previous version",
+		Long: "Generate the protobuf IDL file of the resource with the given flags and compare it with a\n" +
+			"previous version, given as a .proto file or as a descriptor set, reporting its wire and\n" +
+			"source incompatibilities. It fails if any is found.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cfg.complete(args[0]); err != nil {
+				return err
+			}
+
+			s := &schemaBuilder{cfg: cfg}
+			fd, err := s.Build()
+			if err != nil {
+				return fmt.Errorf("failed to generate file descriptor: %v", err)
+			}
+
+			previous, err := loadPreviousFile(cfg, against)
+			if err != nil {
+				return err
+			}
+
+			changes := compareFiles(previous, fd.AsFileDescriptorProto())
+			for _, c := range changes {
+				fmt.Fprintln(cmd.OutOrStdout(), c)
+			}
+			if len(changes) > 0 {
+				cmd.SilenceUsage = true
+				return fmt.Errorf("found %d breaking changes against %s", len(changes), against)
+			}
+
+			return nil
+		},
+	}
+
+	cfg.registerFlags(cmd.Flags())
+	cmd.MarkFlagRequired("package")
+	cmd.MarkFlagRequired("service")
+
+	cmd.Flags().StringVar(&against, "against", "", "Previous version of the file, as a .proto file or a binary FileDescriptorSet")
+	cmd.MarkFlagRequired("against")
+
+	return cmd
+}
+
+// loadPreviousFile loads the previous version of the file of the resource from
+// a .proto file, whose imports are resolved relative to its directory or from
+// the linked descriptors, or from a binary descriptor set, in which it is the
+// file declaring the resource message.
+func loadPreviousFile(c *Config, path string) (*descriptorpb.FileDescriptorProto, error) {
+	if filepath.Ext(path) == ".proto" {
+		p := protoparse.Parser{
+			ImportPaths:  []string{filepath.Dir(path)},
+			LookupImport: desc.LoadFileDescriptor,
+		}
+		fds, err := p.ParseFiles(filepath.Base(path))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+		}
+		return fds[0].AsFileDescriptorProto(), nil
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	set := &descriptorpb.FileDescriptorSet{}
+	if err := proto.Unmarshal(b, set); err != nil {
+		return nil, fmt.Errorf("failed to read the descriptor set %s: %v", path, err)
+	}
+	for _, f := range set.GetFile() {
+		if f.GetPackage() != c.Package {
+			continue
+		}
+		for _, m := range f.GetMessageType() {
+			if m.GetName() == c.Resource {
+				return f, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no file of the descriptor set %s declares %s.%s", path, c.Package, c.Resource)
+}
+
+// compareFiles returns the breaking changes of next against previous.
+func compareFiles(previous, next *descriptorpb.FileDescriptorProto) []breakingChange {
+	d := &differ{
+		previous: indexFile(previous),
+		next:     indexFile(next),
+	}
+
+	for _, name := range d.previous.messageNames {
+		d.compareMessage(name)
+	}
+	for _, name := range d.previous.enumNames {
+		d.compareEnum(name)
+	}
+	for _, s := range previous.GetService() {
+		d.compareService(previous.GetPackage(), s, next.GetService())
+	}
+
+	return d.changes
+}
+
+// fileIndex holds the messages and enums of a file by full name, including the
+// nested ones, and their names in the order of declaration.
+type fileIndex struct {
+	messages     map[string]*descriptorpb.DescriptorProto
+	messageNames []string
+	enums        map[string]*descriptorpb.EnumDescriptorProto
+	enumNames    []string
+}
+
+func indexFile(f *descriptorpb.FileDescriptorProto) *fileIndex {
+	idx := &fileIndex{
+		messages: map[string]*descriptorpb.DescriptorProto{},
+		enums:    map[string]*descriptorpb.EnumDescriptorProto{},
+	}
+	idx.add("."+f.GetPackage(), f.GetMessageType(), f.GetEnumType())
+	return idx
+}
+
+func (idx *fileIndex) add(prefix string, messages []*descriptorpb.DescriptorProto, enums []*descriptorpb.EnumDescriptorProto) {
+	for _, e := range enums {
+		name := prefix + "." + e.GetName()
+		idx.enums[name] = e
+		idx.enumNames = append(idx.enumNames, name)
+	}
+	for _, m := range messages {
+		name := prefix + "." + m.GetName()
+		idx.messages[name] = m
+		if !m.GetOptions().GetMapEntry() {
+			idx.messageNames = append(idx.messageNames, name)
+		}
+		idx.add(name, m.GetNestedType(), m.GetEnumType())
+	}
+}
+
+// typeName returns the type of a field as written in a .proto file, e.g.
+// `repeated string` or `map<string, .acme.v1.Project>`.
+func (idx *fileIndex) typeName(f *descriptorpb.FieldDescriptorProto) string {
+	if entry := idx.messages[f.GetTypeName()]; entry.GetOptions().GetMapEntry() {
+		return "map<" + idx.typeName(entry.GetField()[0]) + ", " + idx.typeName(entry.GetField()[1]) + ">"
+	}
+
+	name := f.GetTypeName()
+	if name == "" {
+		name = strings.ToLower(strings.TrimPrefix(f.GetType().String(), "TYPE_"))
+	}
+	if f.GetLabel() == descriptorpb.FieldDescriptorProto_LABEL_REPEATED {
+		name = "repeated " + name
+	}
+	return name
+}
+
+type differ struct {
+	previous, next *fileIndex
+	changes        []breakingChange
+}
+
+func (d *differ) report(kind, element, format string, args ...any) {
+	d.changes = append(d.changes, breakingChange{Kind: kind, Element: strings.TrimPrefix(element, "."), Message: fmt.Sprintf(format, args...)})
+}
+
+func (d *differ) compareMessage(name string) {
+	previous, next := d.previous.messages[name], d.next.messages[name]
+	if next == nil {
+		d.report(breakingSource, name, "message removed")
+		return
+	}
+
+	byNumber := map[int32]*descriptorpb.FieldDescriptorProto{}
+	byName := map[string]*descriptorpb.FieldDescriptorProto{}
+	for _, f := range next.GetField() {
+		byNumber[f.GetNumber()] = f
+		byName[f.GetName()] = f
+	}
+	previousByName := map[string]*descriptorpb.FieldDescriptorProto{}
+	for _, f := range previous.GetField() {
+		previousByName[f.GetName()] = f
+	}
+
+	for _, f := range previous.GetField() {
+		field := name + "." + f.GetName()
+
+		// A field found by name at another number was moved, and is compared
+		// with its new version rather than with the field now at its number.
+		if nf := byName[f.GetName()]; nf != nil && nf.GetNumber() != f.GetNumber() {
+			d.report(breakingWire, field, "field renumbered from %d to %d", f.GetNumber(), nf.GetNumber())
+			d.compareField(field, previous, f, next, nf)
+			continue
+		}
+
+		nf := byNumber[f.GetNumber()]
+		if nf == nil {
+			if isReserved(next, f) {
+				d.report(breakingSource, field, "field removed")
+			} else {
+				d.report(breakingWire, field, "field removed without reserving its number %d", f.GetNumber())
+			}
+			continue
+		}
+
+		if nf.GetName() != f.GetName() {
+			if previousByName[nf.GetName()] != nil {
+				// Another field was moved to the number of f, which is
+				// reported with that field.
+				d.report(breakingWire, field, "field removed without reserving its number %d", f.GetNumber())
+				continue
+			}
+			d.report(breakingWire, field, "field %d renamed to %s, changing its JSON name", f.GetNumber(), nf.GetName())
+		}
+		d.compareField(field, previous, f, next, nf)
+	}
+
+	previousResource, nextResource := resourceDescriptor(previous), resourceDescriptor(next)
+	if previousResource == nil {
+		return
+	}
+	if nextResource == nil {
+		d.report(breakingSource, name, "resource annotation removed")
+		return
+	}
+	if previousResource.GetType() != nextResource.GetType() {
+		d.report(breakingSource, name, "resource type changed from %s to %s", previousResource.GetType(), nextResource.GetType())
+	}
+	for _, pattern := range previousResource.GetPattern() {
+		if !slices.Contains(nextResource.GetPattern(), pattern) {
+			d.report(breakingSource, name, "resource pattern %s removed", pattern)
+		}
+	}
+}
+
+// compareField reports the incompatible changes of the field f of previous,
+// found as nf in next.
+func (d *differ) compareField(field string, previous *descriptorpb.DescriptorProto, f *descriptorpb.FieldDescriptorProto, next *descriptorpb.DescriptorProto, nf *descriptorpb.FieldDescriptorProto) {
+	if previousType, nextType := d.previous.typeName(f), d.next.typeName(nf); previousType != nextType {
+		d.report(breakingWire, field, "field retyped from %s to %s", previousType, nextType)
+	}
+	if !slices.Contains(fieldBehaviors(f), annotations.FieldBehavior_REQUIRED) &&
+		slices.Contains(fieldBehaviors(nf), annotations.FieldBehavior_REQUIRED) {
+		d.report(breakingSource, field, "field behavior tightened to REQUIRED")
+	}
+
+	switch previousOneof, nextOneof := oneofName(previous, f), oneofName(next, nf); {
+	case previousOneof == nextOneof:
+	case previousOneof == "":
+		d.report(breakingWire, field, "field moved into oneof %s", nextOneof)
+	case nextOneof == "":
+		d.report(breakingWire, field, "field moved out of oneof %s", previousOneof)
+	default:
+		d.report(breakingWire, field, "field moved from oneof %s to oneof %s", previousOneof, nextOneof)
+	}
+}
+
+// oneofName returns the name of the oneof of the field f of m, or an empty
+// string if f is not part of one. The synthetic oneofs of proto3 optional
+// fields are left out.
+func oneofName(m *descriptorpb.DescriptorProto, f *descriptorpb.FieldDescriptorProto) string {
+	if f.OneofIndex == nil || f.GetProto3Optional() {
+		return ""
+	}
+	return m.GetOneofDecl()[f.GetOneofIndex()].GetName()
+}
+
+// isReserved reports whether the number of f is reserved by m.
+func isReserved(m *descriptorpb.DescriptorProto, f *descriptorpb.FieldDescriptorProto) bool {
+	for _, r := range m.GetReservedRange() {
+		// Reserved ranges are stored with an exclusive end.
+		if r.GetStart() <= f.GetNumber() && f.GetNumber() < r.GetEnd() {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *differ) compareEnum(name string) {
+	previous, next := d.previous.enums[name], d.next.enums[name]
+	if next == nil {
+		d.report(breakingSource, name, "enum removed")
+		return
+	}
+
+	numbers := map[string]int32{}
+	for _, v := range next.GetValue() {
+		numbers[v.GetName()] = v.GetNumber()
+	}
+	for _, v := range previous.GetValue() {
+		n, ok := numbers[v.GetName()]
+		switch {
+		case !ok:
+			d.report(breakingWire, name+"."+v.GetName(), "enum value removed")
+		case n != v.GetNumber():
+			d.report(breakingWire, name+"."+v.GetName(), "enum value renumbered from %d to %d", v.GetNumber(), n)
+		}
+	}
+}
+
+func (d *differ) compareService(pkg string, previous *descriptorpb.ServiceDescriptorProto, services []*descriptorpb.ServiceDescriptorProto) {
+	name := pkg + "." + previous.GetName()
+
+	i := slices.IndexFunc(services, func(s *descriptorpb.ServiceDescriptorProto) bool { return s.GetName() == previous.GetName() })
+	if i < 0 {
+		d.report(breakingWire, name, "service removed")
+		return
+	}
+
+	methods := map[string]*descriptorpb.MethodDescriptorProto{}
+	for _, m := range services[i].GetMethod() {
+		methods[m.GetName()] = m
+	}
+
+	for _, m := range previous.GetMethod() {
+		method := name + "." + m.GetName()
+
+		nm := methods[m.GetName()]
+		if nm == nil {
+			d.report(breakingWire, method, "method removed")
+			continue
+		}
+
+		if m.GetInputType() != nm.GetInputType() {
+			d.report(breakingWire, method, "request type changed from %s to %s", strings.TrimPrefix(m.GetInputType(), "."), strings.TrimPrefix(nm.GetInputType(), "."))
+		}
+		if m.GetOutputType() != nm.GetOutputType() {
+			d.report(breakingWire, method, "response type changed from %s to %s", strings.TrimPrefix(m.GetOutputType(), "."), strings.TrimPrefix(nm.GetOutputType(), "."))
+		}
+		if m.GetClientStreaming() != nm.GetClientStreaming() || m.GetServerStreaming() != nm.GetServerStreaming() {
+			d.report(breakingWire, method, "streaming changed")
+		}
+		if previousRule, nextRule := httpBinding(m), httpBinding(nm); previousRule != nextRule {
+			d.report(breakingWire, method, "HTTP binding changed from %q to %q", previousRule, nextRule)
+		}
+	}
+}
+
+// decodeOptions decodes the options src into dst anew, as the options of
+// parsed files may hold their extensions as unknown fields.
+func decodeOptions(src, dst proto.Message) {
+	if b, err := proto.Marshal(src); err == nil {
+		_ = proto.Unmarshal(b, dst)
+	}
+}
+
+func fieldBehaviors(f *descriptorpb.FieldDescriptorProto) []annotations.FieldBehavior {
+	opts := &descriptorpb.FieldOptions{}
+	decodeOptions(f.GetOptions(), opts)
+	behaviors, _ := proto.GetExtension(opts, annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+	return behaviors
+}
+
+func resourceDescriptor(m *descriptorpb.DescriptorProto) *annotations.ResourceDescriptor {
+	opts := &descriptorpb.MessageOptions{}
+	decodeOptions(m.GetOptions(), opts)
+	rd, _ := proto.GetExtension(opts, annotations.E_Resource).(*annotations.ResourceDescriptor)
+	return rd
+}
+
+// httpBinding returns the HTTP binding of a method and its additional
+// bindings, e.g. `GET /v1/{name=projects/*}`, or an empty string if it has
+// none.
+func httpBinding(m *descriptorpb.MethodDescriptorProto) string {
+	rule := methodHTTPRule(m)
+	if rule == nil {
+		return ""
+	}
+
+	bindings := []string{httpRuleString(rule)}
+	for _, r := range rule.GetAdditionalBindings() {
+		bindings = append(bindings, httpRuleString(r))
+	}
+	return strings.Join(bindings, ", ")
+}
+
+// methodHTTPRule returns the HTTP binding of m, if any.
+func methodHTTPRule(m *descriptorpb.MethodDescriptorProto) *annotations.HttpRule {
+	opts := &descriptorpb.MethodOptions{}
+	decodeOptions(m.GetOptions(), opts)
+	rule, _ := proto.GetExtension(opts, annotations.E_Http).(*annotations.HttpRule)
+	return rule
+}
+
+func httpRuleString(rule *annotations.HttpRule) string {
+	verb, path := httpRulePath(rule)
+	s := verb + " " + path
+	if rule.GetBody() != "" {
+		s += " body:" + rule.GetBody()
+	}
+	return s
+}
+
+// httpRulePath returns the HTTP verb and the path template of rule.
+func httpRulePath(rule *annotations.HttpRule) (verb, path string) {
+	switch p := rule.GetPattern().(type) {
+	case *annotations.HttpRule_Get:
+		verb, path = "GET", p.Get
+	case *annotations.HttpRule_Put:
+		verb, path = "PUT", p.Put
+	case *annotations.HttpRule_Post:
+		verb, path = "POST", p.Post
+	case *annotations.HttpRule_Delete:
+		verb, path = "DELETE", p.Delete
+	case *annotations.HttpRule_Patch:
+		verb, path = "PATCH", p.Patch
+	case *annotations.HttpRule_Custom:
+		verb, path = p.Custom.GetKind(), p.Custom.GetPath()
+	}
+	return verb, path
+}
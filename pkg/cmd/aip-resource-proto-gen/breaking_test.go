@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+// parse returns the descriptor of the proto file src.
+func parse(t *testing.T, src string) *descriptorpb.FileDescriptorProto {
+	t.Helper()
+
+	p := protoparse.Parser{
+		Accessor:     protoparse.FileContentsFromMap(map[string]string{"test.proto": src}),
+		LookupImport: desc.LoadFileDescriptor,
+	}
+	fds, err := p.ParseFiles("test.proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fds[0].AsFileDescriptorProto()
+}
+
+func TestCompareFiles(t *testing.T) {
+	const header = `syntax = "proto3"; package acme.v1; import "google/api/field_behavior.proto"; `
+
+	tests := []struct {
+		name           string
+		previous, next string
+		want           []string
+	}{
+		{
+			"unchanged",
+			`message M { string a = 1; }`,
+			`message M { string a = 1; }`,
+			nil,
+		},
+		{
+			"field added",
+			`message M { string a = 1; }`,
+			`message M { string a = 1; string b = 2; }`,
+			nil,
+		},
+		{
+			"field removed",
+			`message M { string a = 1; string b = 2; }`,
+			`message M { string a = 1; }`,
+			[]string{"wire: acme.v1.M.b: field removed without reserving its number 2"},
+		},
+		{
+			"field removed and reserved",
+			`message M { string a = 1; string b = 2; }`,
+			`message M { string a = 1; reserved 2; }`,
+			[]string{"source: acme.v1.M.b: field removed"},
+		},
+		{
+			"field renamed",
+			`message M { string a = 1; }`,
+			`message M { string b = 1; }`,
+			[]string{"wire: acme.v1.M.a: field 1 renamed to b, changing its JSON name"},
+		},
+		{
+			"field retyped",
+			`message M { string a = 1; map<string, string> b = 2; }`,
+			`message M { int64 a = 1; repeated string b = 2; }`,
+			[]string{
+				"wire: acme.v1.M.a: field retyped from string to int64",
+				"wire: acme.v1.M.b: field retyped from map<string, string> to repeated string",
+			},
+		},
+		{
+			"field renumbered",
+			`message M { string a = 1; int32 b = 2; }`,
+			`message M { string x = 1; string a = 2; int32 b = 3; }`,
+			[]string{
+				"wire: acme.v1.M.a: field renumbered from 1 to 2",
+				"wire: acme.v1.M.b: field renumbered from 2 to 3",
+			},
+		},
+		{
+			"field renumbered and retyped",
+			`message M { string a = 1; }`,
+			`message M { int32 a = 2; }`,
+			[]string{
+				"wire: acme.v1.M.a: field renumbered from 1 to 2",
+				"wire: acme.v1.M.a: field retyped from string to int32",
+			},
+		},
+		{
+			"fields swapped",
+			`message M { string a = 1; int32 b = 2; }`,
+			`message M { int32 b = 1; string a = 2; }`,
+			[]string{
+				"wire: acme.v1.M.a: field renumbered from 1 to 2",
+				"wire: acme.v1.M.b: field renumbered from 2 to 1",
+			},
+		},
+		{
+			"field removed and its number reused by a moved field",
+			`message M { string a = 1; string b = 2; }`,
+			`message M { string b = 1; }`,
+			[]string{
+				"wire: acme.v1.M.a: field removed without reserving its number 1",
+				"wire: acme.v1.M.b: field renumbered from 2 to 1",
+			},
+		},
+		{
+			"field behavior tightened",
+			`message M { string a = 1 [(google.api.field_behavior) = OPTIONAL]; }`,
+			`message M { string a = 1 [(google.api.field_behavior) = REQUIRED]; }`,
+			[]string{"source: acme.v1.M.a: field behavior tightened to REQUIRED"},
+		},
+		{
+			"field moved into a oneof",
+			`message M { string a = 1; }`,
+			`message M { oneof o { string a = 1; } }`,
+			[]string{"wire: acme.v1.M.a: field moved into oneof o"},
+		},
+		{
+			"field moved out of a oneof",
+			`message M { oneof o { string a = 1; } }`,
+			`message M { string a = 1; }`,
+			[]string{"wire: acme.v1.M.a: field moved out of oneof o"},
+		},
+		{
+			"field moved to another oneof",
+			`message M { oneof o { string a = 1; } }`,
+			`message M { oneof p { string a = 1; } }`,
+			[]string{"wire: acme.v1.M.a: field moved from oneof o to oneof p"},
+		},
+		{
+			"field made optional",
+			`message M { string a = 1; }`,
+			`message M { optional string a = 1; }`,
+			nil,
+		},
+		{
+			"message removed",
+			`message M { string a = 1; } message N {}`,
+			`message M { string a = 1; }`,
+			[]string{"source: acme.v1.N: message removed"},
+		},
+		{
+			"enum value removed",
+			`enum E { E_UNSPECIFIED = 0; A = 1; B = 2; }`,
+			`enum E { E_UNSPECIFIED = 0; A = 1; reserved 2; }`,
+			[]string{"wire: acme.v1.E.B: enum value removed"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := compareFiles(parse(t, header+tt.previous), parse(t, header+tt.next))
+			if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("compareFiles() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCompareFilesBaseline(t *testing.T) {
+	// The files generated by the first releases are compatible with the
+	// current ones, including with the fields since added.
+	tests := []struct {
+		previous string
+		args     []string
+	}{
+		{"v0.proto", nil},
+		{"v0.proto", []string{"--resource-with-etag", "--with-request-id", "--with-validate-only"}},
+		{"v0_parent.proto", []string{"--resource-parent", "organizations/{organization}"}},
+		{"v0_parent.proto", []string{
+			"--resource-parent", "organizations/{organization}",
+			"--resource-with-uid", "--resource-with-etag", "--with-read-mask", "--with-list-skip",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.previous, tt.args), func(t *testing.T) {
+			cfg := newConfig(t, "Project", tt.args...)
+			previous, err := loadPreviousFile(cfg, filepath.Join("testdata", tt.previous))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := compareFiles(previous, build(t, "Project", tt.args...).AsFileDescriptorProto()); len(got) > 0 {
+				t.Errorf("compareFiles() = %v, want no change", got)
+			}
+		})
+	}
+}
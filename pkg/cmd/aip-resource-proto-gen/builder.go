@@ -4,13 +4,16 @@ import (
 	"fmt"
 	"strings"
 
+	"cloud.google.com/go/iam/apiv1/iampb"
 	"github.com/jhump/protoreflect/desc"
 	"github.com/jhump/protoreflect/desc/builder"
 	"github.com/jhump/protoreflect/desc/protoprint"
 	"github.com/stoewer/go-strcase"
 	"google.golang.org/genproto/googleapis/api/annotations"
 	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/runtime/protoiface"
 	"google.golang.org/protobuf/types/descriptorpb"
+	"google.golang.org/protobuf/types/known/durationpb"
 	"google.golang.org/protobuf/types/known/emptypb"
 	"google.golang.org/protobuf/types/known/fieldmaskpb"
 	"google.golang.org/protobuf/types/known/timestamppb"
@@ -21,6 +24,7 @@ type schemaBuilder struct {
 
 	file     *builder.FileBuilder
 	resource *builder.MessageBuilder
+	view     *builder.EnumBuilder
 	service  *builder.ServiceBuilder
 }
 
@@ -35,13 +39,22 @@ func (s *schemaBuilder) buildDescriptor() (*desc.FileDescriptor, error) {
 
 	s.file = b
 
-	s.buildResourceMessage()
-	s.buildServiceDescriptor()
+	if err := s.buildResourceMessage(); err != nil {
+		return nil, err
+	}
+	s.buildViewEnum()
+	if err := s.buildServiceDescriptor(); err != nil {
+		return nil, err
+	}
+
+	if err := s.numberFields(); err != nil {
+		return nil, err
+	}
 
 	return b.Build()
 }
 
-func (s *schemaBuilder) buildResourceMessage() {
+func (s *schemaBuilder) buildResourceMessage() error {
 	c := s.cfg
 
 	b := builder.NewMessage(c.Resource)
@@ -49,6 +62,7 @@ func (s *schemaBuilder) buildResourceMessage() {
 	b.SetOptions(messageOptions(resource(
 		&annotations.ResourceDescriptor{
 			Type:     c.ResourceTypeName(),
+			Pattern:  []string{c.ResourceNamePattern()},
 			Singular: strcase.LowerCamelCase(c.Resource),
 			Plural:   c.ResourceCollectionIdentifier(),
 		},
@@ -66,10 +80,17 @@ func (s *schemaBuilder) buildResourceMessage() {
 		b.AddField(displayNameField)
 	}
 
+	if err := addCustomFields(c, b); err != nil {
+		return err
+	}
+	if err := addReserved(c, b); err != nil {
+		return err
+	}
+
 	if c.WithTimestamps {
 		tsDesc, err := desc.LoadMessageDescriptorForMessage((*timestamppb.Timestamp)(nil))
 		if err != nil {
-			panic(err)
+			return err
 		}
 
 		ts := builder.FieldTypeImportedMessage(tsDesc)
@@ -92,11 +113,127 @@ func (s *schemaBuilder) buildResourceMessage() {
 		b.AddField(annotationsField)
 	}
 
+	if c.WithUID {
+		uidField := builder.NewField("uid", builder.FieldTypeString())
+		uidField.SetComments(comment("The system-assigned unique identifier of the resource.", ""))
+		uidField.SetOptions(fieldOptions(outputOnly(), fieldInfo(annotations.FieldInfo_UUID4)))
+		b.AddField(uidField)
+	}
+
+	if c.WithEtag {
+		etagField := builder.NewField("etag", builder.FieldTypeString())
+		etagField.SetComments(comment("This checksum is computed by the server based on the value of other fields,\n"+
+			"and may be sent on update and delete requests to ensure the client has an\n"+
+			"up-to-date value before proceeding.", ""))
+		etagField.SetOptions(fieldOptions(optional()))
+		b.AddField(etagField)
+	}
+
+	if c.WithReconciling {
+		reconcilingField := builder.NewField("reconciling", builder.FieldTypeBool())
+		reconcilingField.SetComments(comment("Whether the resource is currently being reconciled, i.e. whether its\n"+
+			"current state differs from its intended state and the service is working\n"+
+			"to converge them.", ""))
+		reconcilingField.SetOptions(fieldOptions(outputOnly()))
+		b.AddField(reconcilingField)
+	}
+
+	if len(c.States) > 0 {
+		state := builder.NewEnum("State")
+		state.SetComments(comment("The states of the lifecycle of the resource.", ""))
+
+		unspecified := builder.NewEnumValue("STATE_UNSPECIFIED").SetNumber(0)
+		unspecified.SetComments(comment("Unspecified.", ""))
+		state.AddValue(unspecified)
+
+		for i, name := range c.States {
+			v := builder.NewEnumValue(name).SetNumber(int32(i + 1))
+			v.SetComments(comment("The resource is "+strings.ToLower(strings.ReplaceAll(name, "_", " "))+".", ""))
+			state.AddValue(v)
+		}
+		b.AddNestedEnum(state)
+
+		stateField := builder.NewField("state", builder.FieldTypeEnum(state))
+		stateField.SetComments(comment("The state of the resource.", ""))
+		stateField.SetOptions(fieldOptions(outputOnly()))
+		b.AddField(stateField)
+	}
+
+	if c.WithLabels {
+		labelsField := builder.NewMapField("labels", builder.FieldTypeString(), builder.FieldTypeString())
+		labelsField.SetComments(comment("Labels defined by the caller, which can be used to filter and group resources.", ""))
+		labelsField.SetOptions(fieldOptions(optional()))
+		b.AddField(labelsField)
+	}
+
+	if c.WithExpiration {
+		ts := builder.FieldTypeImportedMessage(loadMessageDescriptor((*timestamppb.Timestamp)(nil)))
+		duration := builder.FieldTypeImportedMessage(loadMessageDescriptor((*durationpb.Duration)(nil)))
+
+		expireTimeField := builder.NewField("expire_time", ts)
+		expireTimeField.SetComments(comment("The time at which the resource is considered expired. This is always\n"+
+			"provided on output, regardless of what was sent on input.", ""))
+		expireTimeField.SetOptions(fieldOptions(optional()))
+
+		ttlField := builder.NewField("ttl", duration)
+		ttlField.SetComments(comment("The time to live of the resource, from which the server computes the\n"+
+			"expire_time.", ""))
+		ttlField.SetOptions(fieldOptions(inputOnly()))
+
+		expiration := builder.NewOneOf("expiration")
+		expiration.SetComments(comment("The expiration of the resource.", ""))
+		expiration.AddChoice(expireTimeField)
+		expiration.AddChoice(ttlField)
+		b.AddOneOf(expiration)
+	}
+
+	if c.WithRevisions {
+		revisionIDField := builder.NewField("revision_id", builder.FieldTypeString())
+		revisionIDField.SetComments(comment("The revision ID of the resource, assigned when the revision is committed.", ""))
+		revisionIDField.SetOptions(fieldOptions(fieldBehavior(annotations.FieldBehavior_IMMUTABLE, annotations.FieldBehavior_OUTPUT_ONLY)))
+		b.AddField(revisionIDField)
+
+		revisionCreateTimeField := builder.NewField("revision_create_time", builder.FieldTypeImportedMessage(loadMessageDescriptor((*timestamppb.Timestamp)(nil))))
+		revisionCreateTimeField.SetComments(comment("The time at which the revision was committed.", ""))
+		revisionCreateTimeField.SetOptions(fieldOptions(outputOnly()))
+		b.AddField(revisionCreateTimeField)
+	}
+
 	s.file.AddMessage(b)
 	s.resource = b
+
+	return nil
+}
+
+func (s *schemaBuilder) buildViewEnum() {
+	c := s.cfg
+	if !c.WithView {
+		return
+	}
+
+	prefix := strcase.UpperSnakeCase(c.ResourceViewName()) + "_"
+
+	b := builder.NewEnum(c.ResourceViewName())
+	b.SetComments(comment("The view of the "+c.Resource+" resource returned by the Get and List methods.", ""))
+
+	unspecified := builder.NewEnumValue(prefix + "UNSPECIFIED").SetNumber(0)
+	unspecified.SetComments(comment("The default / unset value. The API defaults to the BASIC view for the List\n"+
+		"method and to the FULL view for the Get method.", ""))
+	b.AddValue(unspecified)
+
+	basic := builder.NewEnumValue(prefix + "BASIC").SetNumber(1)
+	basic.SetComments(comment("Include the basic metadata of the resource, but not its full contents.", ""))
+	b.AddValue(basic)
+
+	full := builder.NewEnumValue(prefix + "FULL").SetNumber(2)
+	full.SetComments(comment("Include everything.", ""))
+	b.AddValue(full)
+
+	s.file.AddEnum(b)
+	s.view = b
 }
 
-func (s *schemaBuilder) buildServiceDescriptor() {
+func (s *schemaBuilder) buildServiceDescriptor() error {
 	c := s.cfg
 
 	b := builder.NewService(c.Resource)
@@ -110,9 +247,19 @@ func (s *schemaBuilder) buildServiceDescriptor() {
 	s.buildGetMethod()
 	s.buildListMethod()
 	s.buildCreateMethod()
-	s.buildUpdateMethod()
+	if err := s.buildUpdateMethod(); err != nil {
+		return err
+	}
 	s.buildDeleteMethod()
+	s.buildSearchMethod()
+	if err := s.buildStateTransitionMethods(); err != nil {
+		return err
+	}
+	s.buildWatchMethods()
+	s.buildRevisionMethods()
+	s.buildIAMMethods()
 
+	return nil
 }
 
 func (s *schemaBuilder) buildGetMethod() {
@@ -127,10 +274,17 @@ func (s *schemaBuilder) buildGetMethod() {
 	req := builder.NewMessage(reqType)
 	req.SetComments(comment("Request for "+name+" method.", ""))
 	nameField := builder.NewField("name", builder.FieldTypeString())
-	nameField.SetComments(comment("The name of the resource to retrieve.", ""))
+	if c.WithRevisions {
+		nameField.SetComments(comment("The name of the resource to retrieve. Append `@` and a revision ID to the\n"+
+			"name to retrieve a specific revision.", ""))
+	} else {
+		nameField.SetComments(comment("The name of the resource to retrieve.", ""))
+	}
 	nameField.SetOptions(fieldOptions(required()))
 	req.AddField(nameField)
 
+	s.addReadFields(req)
+
 	reqRpc := builder.RpcTypeMessage(req, false)
 
 	resRpc := builder.RpcTypeMessage(s.resource, false)
@@ -165,20 +319,17 @@ func (s *schemaBuilder) buildListMethod() {
 
 	if c.HasParent() {
 		parentField := builder.NewField("parent", builder.FieldTypeString())
-		parentField.SetComments(comment("The resource's parent.", ""))
+		if c.WithListWildcardParent {
+			parentField.SetComments(comment("The resource's parent. Use `-` as the wildcard for any of its IDs to list\n"+
+				"the resources across parents, e.g. `"+wildcardPattern(c.ParentPattern)+"`.", ""))
+		} else {
+			parentField.SetComments(comment("The resource's parent.", ""))
+		}
 		parentField.SetOptions(fieldOptions(required()))
 		req.AddField(parentField)
 	}
 
-	pageSizeField := builder.NewField("page_size", builder.FieldTypeInt32())
-	pageSizeField.SetComments(comment("The maximum number of resources to return.", ""))
-	pageSizeField.SetOptions(fieldOptions(optional()))
-	req.AddField(pageSizeField)
-
-	pageTokenField := builder.NewField("page_token", builder.FieldTypeString())
-	pageTokenField.SetComments(comment("The page token to use for pagination. Provide this to retrieve subsequent page", ""))
-	pageTokenField.SetOptions(fieldOptions(optional()))
-	req.AddField(pageTokenField)
+	addPageFields(req)
 
 	if c.WithListFilter {
 		filterField := builder.NewField("filter", builder.FieldTypeString())
@@ -194,6 +345,17 @@ func (s *schemaBuilder) buildListMethod() {
 		req.AddField(orderByField)
 	}
 
+	if c.WithListSkip {
+		skipField := builder.NewField("skip", builder.FieldTypeInt32())
+		skipField.SetComments(comment("The number of individual resources to skip before returning the first page.\n"+
+			"The next pages must be requested with the same skip, which their page_token\n"+
+			"already accounts for.", ""))
+		skipField.SetOptions(fieldOptions(optional()))
+		req.AddField(skipField)
+	}
+
+	s.addReadFields(req)
+
 	reqRpc := builder.RpcTypeMessage(req, false)
 
 	// Response
@@ -201,19 +363,31 @@ func (s *schemaBuilder) buildListMethod() {
 	res := builder.NewMessage(resType)
 	res.SetComments(comment("Response for "+name+" method.", ""))
 
-	resourceField := builder.NewField(c.PluralResourceSnakeCase(), builder.FieldTypeMessage(s.resource))
-	resourceField.SetRepeated()
-	resourceField.SetComments(comment("The list of "+c.Resource+" resources.", ""))
-	res.AddField(resourceField)
+	s.addPageResultFields(res)
 
-	nextTokenField := builder.NewField("next_page_token", builder.FieldTypeString())
-	nextTokenField.SetComments(comment("The token to retrieve the next page of results, or empty if there are no more results.", ""))
-	res.AddField(nextTokenField)
+	if c.WithListTotalSize {
+		totalSizeField := builder.NewField("total_size", builder.FieldTypeInt32())
+		totalSizeField.SetComments(comment("The total number of resources matching the request, across all pages.", ""))
+		res.AddField(totalSizeField)
+	}
+
+	if c.WithListUnreachable {
+		unreachableField := builder.NewField("unreachable", builder.FieldTypeString())
+		unreachableField.SetRepeated()
+		unreachableField.SetComments(comment("Unordered list. The names of the resources or locations that could not be\n"+
+			"reached, whose resources are missing from the results.", ""))
+		unreachableField.SetOptions(fieldOptions(unorderedList()))
+		res.AddField(unreachableField)
+	}
 
 	resRpc := builder.RpcTypeMessage(res, false)
 
 	m := builder.NewMethod(name, reqRpc, resRpc)
-	m.SetComments(comment("List the "+c.Resource+" resources", ""))
+	if c.HasParent() && c.WithListWildcardParent {
+		m.SetComments(comment("List the "+c.Resource+" resources, possibly across parents", ""))
+	} else {
+		m.SetComments(comment("List the "+c.Resource+" resources", ""))
+	}
 	if c.WithHTTPOptions {
 		parentVar := ""
 		methodSig := ""
@@ -267,6 +441,15 @@ func (s *schemaBuilder) buildCreateMethod() {
 	resourceField.SetComments(comment("The "+c.Resource+" resource to create.", ""))
 	resourceField.SetOptions(fieldOptions(required()))
 	req.AddField(resourceField)
+
+	if c.WithRequestID {
+		req.AddField(requestIDField())
+	}
+
+	if c.WithValidateOnly {
+		req.AddField(validateOnlyField())
+	}
+
 	reqRpc := builder.RpcTypeMessage(req, false)
 
 	// Response Message
@@ -299,9 +482,9 @@ func (s *schemaBuilder) buildCreateMethod() {
 	s.service.AddMethod(m)
 }
 
-func (s *schemaBuilder) buildUpdateMethod() {
+func (s *schemaBuilder) buildUpdateMethod() error {
 	if !strings.Contains(s.cfg.Methods, "u") {
-		return
+		return nil
 	}
 
 	c := s.cfg
@@ -321,7 +504,7 @@ func (s *schemaBuilder) buildUpdateMethod() {
 	if s.cfg.WithUpdateFieldMask {
 		fieldMaskDesc, err := desc.LoadMessageDescriptorForMessage((*fieldmaskpb.FieldMask)(nil))
 		if err != nil {
-			panic(err)
+			return err
 		}
 		fm := builder.FieldTypeImportedMessage(fieldMaskDesc)
 		updateMaskField := builder.NewField("update_mask", fm)
@@ -337,13 +520,26 @@ func (s *schemaBuilder) buildUpdateMethod() {
 		req.AddField(allowMissingField)
 	}
 
+	if s.cfg.WithRequestID {
+		req.AddField(requestIDField())
+	}
+
+	if s.cfg.WithValidateOnly {
+		req.AddField(validateOnlyField())
+	}
+
 	reqRpc := builder.RpcTypeMessage(req, false)
 
 	// Response message
 	resRpc := builder.RpcTypeMessage(s.resource, false)
 
+	immutable, err := s.immutableFieldsComment()
+	if err != nil {
+		return err
+	}
+
 	m := builder.NewMethod(name, reqRpc, resRpc)
-	m.SetComments(comment("Update the "+c.Resource+" resource", ""))
+	m.SetComments(comment("Update the "+c.Resource+" resource"+immutable, ""))
 	if c.WithHTTPOptions {
 		nameVar := fmt.Sprintf("{%s.name=%s}", c.ResourceSnakeCase(), c.ResourceNameUrlRef())
 		methodSig := c.ResourceSnakeCase()
@@ -362,6 +558,8 @@ func (s *schemaBuilder) buildUpdateMethod() {
 
 	s.file.AddMessage(req)
 	s.service.AddMethod(m)
+
+	return nil
 }
 
 func (s *schemaBuilder) buildDeleteMethod() {
@@ -389,6 +587,30 @@ func (s *schemaBuilder) buildDeleteMethod() {
 		req.AddField(allowMissingField)
 	}
 
+	if c.WithEtag {
+		etagField := builder.NewField("etag", builder.FieldTypeString())
+		etagField.SetComments(comment("The etag of the resource. If provided, it must match the server's etag\n"+
+			"for the deletion to proceed.", ""))
+		etagField.SetOptions(fieldOptions(optional()))
+		req.AddField(etagField)
+	}
+
+	if c.HasChildren() {
+		forceField := builder.NewField("force", builder.FieldTypeBool())
+		forceField.SetComments(comment("If set to true, any child resources will also be deleted. (Otherwise, the\n"+
+			"request will only work if there are no child resources.)", ""))
+		forceField.SetOptions(fieldOptions(optional()))
+		req.AddField(forceField)
+	}
+
+	if c.WithRequestID {
+		req.AddField(requestIDField())
+	}
+
+	if c.WithValidateOnly {
+		req.AddField(validateOnlyField())
+	}
+
 	reqRpc := builder.RpcTypeMessage(req, false)
 
 	emptyDesc, err := desc.LoadMessageDescriptorForMessage((*emptypb.Empty)(nil))
@@ -414,6 +636,511 @@ func (s *schemaBuilder) buildDeleteMethod() {
 	s.service.AddMethod(m)
 }
 
+func loadMessageDescriptor(msg protoiface.MessageV1) *desc.MessageDescriptor {
+	d, err := desc.LoadMessageDescriptorForMessage(msg)
+	if err != nil {
+		panic(err)
+	}
+	return d
+}
+
+// requestIDField returns the AIP-155 request_id field of mutating requests.
+func requestIDField() *builder.FieldBuilder {
+	f := builder.NewField("request_id", builder.FieldTypeString())
+	f.SetComments(comment("A unique identifier for this request, so that if the request is retried the\n"+
+		"server can recognize it and return the result of the original request\n"+
+		"instead of processing it again.", ""))
+	f.SetOptions(fieldOptions(optional(), fieldInfo(annotations.FieldInfo_UUID4)))
+	return f
+}
+
+// buildWatchMethods adds the server-streaming methods watching the changes of
+// a resource and of the resources of a collection, streaming events which
+// carry a token to resume the watch after them.
+func (s *schemaBuilder) buildWatchMethods() {
+	c := s.cfg
+	if !c.WithWatch {
+		return
+	}
+
+	// Event
+	event := builder.NewMessage(c.Resource + "Event")
+	event.SetComments(comment("A change of a "+c.Resource+" resource.", ""))
+
+	eventType := builder.NewEnum("Type")
+	eventType.SetComments(comment("The type of a change.", ""))
+	for i, value := range []struct{ name, doc string }{
+		{"TYPE_UNSPECIFIED", "Unspecified."},
+		{"ADDED", "The resource was created."},
+		{"MODIFIED", "The resource was updated."},
+		{"DELETED", "The resource was deleted."},
+	} {
+		v := builder.NewEnumValue(value.name).SetNumber(int32(i))
+		v.SetComments(comment(value.doc, ""))
+		eventType.AddValue(v)
+	}
+	event.AddNestedEnum(eventType)
+
+	typeField := builder.NewField("type", builder.FieldTypeEnum(eventType))
+	typeField.SetComments(comment("The type of the change.", ""))
+	event.AddField(typeField)
+
+	resourceField := builder.NewField(c.ResourceSnakeCase(), builder.FieldTypeMessage(s.resource))
+	resourceField.SetComments(comment("The resource after the change, or its last value when it was deleted.", ""))
+	event.AddField(resourceField)
+
+	eventTokenField := builder.NewField("resume_token", builder.FieldTypeString())
+	eventTokenField.SetComments(comment("The token to resume the watch after this change.", ""))
+	event.AddField(eventTokenField)
+
+	eventRpc := builder.RpcTypeMessage(event, true)
+
+	resumeTokenField := func() *builder.FieldBuilder {
+		f := builder.NewField("resume_token", builder.FieldTypeString())
+		f.SetComments(comment("The resume_token of the last received change, to resume a watch after it.\n"+
+			"If empty, only the changes happening from now on are streamed.", ""))
+		f.SetOptions(fieldOptions(optional()))
+		return f
+	}
+
+	// Watch<Resource>
+	name := "Watch" + c.Resource
+	req := builder.NewMessage(name + "Request")
+	req.SetComments(comment("Request for "+name+" method.", ""))
+
+	nameField := builder.NewField("name", builder.FieldTypeString())
+	nameField.SetComments(comment("The name of the resource to watch.", ""))
+	nameField.SetOptions(fieldOptions(required()))
+	req.AddField(nameField)
+	req.AddField(resumeTokenField())
+
+	m := builder.NewMethod(name, builder.RpcTypeMessage(req, false), eventRpc)
+	m.SetComments(comment("Watch the changes of the "+c.Resource+" resource", ""))
+	if c.WithHTTPOptions {
+		rule := &annotations.HttpRule{
+			Pattern: &annotations.HttpRule_Get{
+				Get: fmt.Sprintf("/v1/{name=%s}:watch", c.ResourceNameUrlRef()),
+			},
+		}
+		m.SetOptions(methodOptions(httpRule(rule), methodSignature("name")))
+	}
+
+	s.file.AddMessage(req)
+	s.service.AddMethod(m)
+
+	// Watch<PluralResource>
+	name = "Watch" + c.PluralResource
+	req = builder.NewMessage(name + "Request")
+	req.SetComments(comment("Request for "+name+" method.", ""))
+
+	if c.HasParent() {
+		parentField := builder.NewField("parent", builder.FieldTypeString())
+		parentField.SetComments(comment("The parent of the resources to watch.", ""))
+		parentField.SetOptions(fieldOptions(required()))
+		req.AddField(parentField)
+	}
+	req.AddField(resumeTokenField())
+
+	m = builder.NewMethod(name, builder.RpcTypeMessage(req, false), eventRpc)
+	m.SetComments(comment("Watch the changes of the "+c.Resource+" resources", ""))
+	if c.WithHTTPOptions {
+		parentVar := ""
+		methodSig := ""
+		if c.HasParent() {
+			parentVar = fmt.Sprintf("{parent=%s}/", c.ParentNameUrlRef())
+			methodSig = "parent"
+		}
+
+		rule := &annotations.HttpRule{
+			Pattern: &annotations.HttpRule_Get{
+				Get: fmt.Sprintf("/v1/%s%s:watch", parentVar, c.ResourceCollectionIdentifier()),
+			},
+		}
+		m.SetOptions(methodOptions(httpRule(rule), methodSignature(methodSig)))
+	}
+
+	s.file.AddMessage(req)
+	s.file.AddMessage(event)
+	s.service.AddMethod(m)
+}
+
+// buildRevisionMethods adds the methods managing the revisions of the
+// resource, as described by AIP-162. A revision is named by appending `@` and
+// its ID to the resource name, which the `*` of the HTTP bindings matches.
+func (s *schemaBuilder) buildRevisionMethods() {
+	c := s.cfg
+	if !c.WithRevisions {
+		return
+	}
+
+	nameVar := fmt.Sprintf("{name=%s}", c.ResourceNameUrlRef())
+
+	newNameField := func(doc string) *builder.FieldBuilder {
+		f := builder.NewField("name", builder.FieldTypeString())
+		f.SetComments(comment(doc, ""))
+		f.SetOptions(fieldOptions(required()))
+		return f
+	}
+
+	newMethod := func(name, doc string, req *builder.MessageBuilder, resRpc *builder.RpcType, rule *annotations.HttpRule, signature string) {
+		m := builder.NewMethod(name, builder.RpcTypeMessage(req, false), resRpc)
+		m.SetComments(comment(doc, ""))
+		if c.WithHTTPOptions {
+			m.SetOptions(methodOptions(httpRule(rule), methodSignature(signature)))
+		}
+
+		s.file.AddMessage(req)
+		s.service.AddMethod(m)
+	}
+
+	resourceRpc := builder.RpcTypeMessage(s.resource, false)
+
+	// Commit
+	commit := builder.NewMessage("Commit" + c.Resource + "Request")
+	commit.SetComments(comment("Request for Commit"+c.Resource+" method.", ""))
+	commit.AddField(newNameField("The name of the resource to commit a revision of."))
+	if c.WithValidateOnly {
+		commit.AddField(validateOnlyField())
+	}
+	newMethod("Commit"+c.Resource, "Commit a new revision of the "+c.Resource+" resource", commit, resourceRpc,
+		&annotations.HttpRule{
+			Pattern: &annotations.HttpRule_Post{Post: fmt.Sprintf("/v1/%s:commit", nameVar)},
+			Body:    "*",
+		}, "name")
+
+	// ListRevisions
+	list := builder.NewMessage("List" + c.Resource + "RevisionsRequest")
+	list.SetComments(comment("Request for List"+c.Resource+"Revisions method.", ""))
+	list.AddField(newNameField("The name of the resource to list the revisions of."))
+
+	pageSizeField := builder.NewField("page_size", builder.FieldTypeInt32())
+	pageSizeField.SetComments(comment("The maximum number of revisions to return.", ""))
+	pageSizeField.SetOptions(fieldOptions(optional()))
+	list.AddField(pageSizeField)
+
+	pageTokenField := builder.NewField("page_token", builder.FieldTypeString())
+	pageTokenField.SetComments(comment("The page token to use for pagination. Provide this to retrieve subsequent page", ""))
+	pageTokenField.SetOptions(fieldOptions(optional()))
+	list.AddField(pageTokenField)
+
+	listRes := builder.NewMessage("List" + c.Resource + "RevisionsResponse")
+	listRes.SetComments(comment("Response for List"+c.Resource+"Revisions method.", ""))
+
+	revisionsField := builder.NewField(c.PluralResourceSnakeCase(), builder.FieldTypeMessage(s.resource))
+	revisionsField.SetRepeated()
+	revisionsField.SetComments(comment("The revisions of the "+c.Resource+" resource, from the most recent.", ""))
+	listRes.AddField(revisionsField)
+
+	nextTokenField := builder.NewField("next_page_token", builder.FieldTypeString())
+	nextTokenField.SetComments(comment("The token to retrieve the next page of results, or empty if there are no more results.", ""))
+	listRes.AddField(nextTokenField)
+
+	newMethod("List"+c.Resource+"Revisions", "List the revisions of the "+c.Resource+" resource", list, builder.RpcTypeMessage(listRes, false),
+		&annotations.HttpRule{
+			Pattern: &annotations.HttpRule_Get{Get: fmt.Sprintf("/v1/%s:listRevisions", nameVar)},
+		}, "name")
+	s.file.AddMessage(listRes)
+
+	// Rollback
+	rollback := builder.NewMessage("Rollback" + c.Resource + "Request")
+	rollback.SetComments(comment("Request for Rollback"+c.Resource+" method.", ""))
+	rollback.AddField(newNameField("The name of the resource to roll back."))
+
+	revisionIDField := builder.NewField("revision_id", builder.FieldTypeString())
+	revisionIDField.SetComments(comment("The revision ID to roll back to. It must be a revision of the same resource.", ""))
+	revisionIDField.SetOptions(fieldOptions(required()))
+	rollback.AddField(revisionIDField)
+
+	if c.WithValidateOnly {
+		rollback.AddField(validateOnlyField())
+	}
+
+	newMethod("Rollback"+c.Resource, "Roll back the "+c.Resource+" resource to a previous revision, committing\n"+
+		"it as a new revision", rollback, resourceRpc,
+		&annotations.HttpRule{
+			Pattern: &annotations.HttpRule_Post{Post: fmt.Sprintf("/v1/%s:rollback", nameVar)},
+			Body:    "*",
+		}, "name,revision_id")
+
+	// DeleteRevision
+	deleteRevision := builder.NewMessage("Delete" + c.Resource + "RevisionRequest")
+	deleteRevision.SetComments(comment("Request for Delete"+c.Resource+"Revision method.", ""))
+	deleteRevision.AddField(newNameField("The name of the revision to delete, i.e. the resource name followed by `@`\n" +
+		"and the revision ID."))
+	if c.WithValidateOnly {
+		deleteRevision.AddField(validateOnlyField())
+	}
+
+	emptyRpc := builder.RpcTypeImportedMessage(loadMessageDescriptor((*emptypb.Empty)(nil)), false)
+	newMethod("Delete"+c.Resource+"Revision", "Delete a revision of the "+c.Resource+" resource", deleteRevision, emptyRpc,
+		&annotations.HttpRule{
+			Pattern: &annotations.HttpRule_Delete{Delete: fmt.Sprintf("/v1/%s:deleteRevision", nameVar)},
+		}, "name")
+}
+
+// buildIAMMethods adds the google.iam.v1 access control methods on the
+// resource, as described by AIP-211.
+func (s *schemaBuilder) buildIAMMethods() {
+	c := s.cfg
+	if !c.WithIAM {
+		return
+	}
+
+	methods := []struct {
+		name      string
+		comment   string
+		verb      string
+		req, res  protoiface.MessageV1
+		signature string
+	}{
+		{
+			name:      "GetIamPolicy",
+			comment:   "Get the access control policy of a " + c.Resource + " resource",
+			verb:      "getIamPolicy",
+			req:       (*iampb.GetIamPolicyRequest)(nil),
+			res:       (*iampb.Policy)(nil),
+			signature: "resource",
+		},
+		{
+			name:      "SetIamPolicy",
+			comment:   "Set the access control policy of a " + c.Resource + " resource, replacing any\nexisting policy",
+			verb:      "setIamPolicy",
+			req:       (*iampb.SetIamPolicyRequest)(nil),
+			res:       (*iampb.Policy)(nil),
+			signature: "resource,policy",
+		},
+		{
+			name:      "TestIamPermissions",
+			comment:   "Return the permissions that the caller has on a " + c.Resource + " resource",
+			verb:      "testIamPermissions",
+			req:       (*iampb.TestIamPermissionsRequest)(nil),
+			res:       (*iampb.TestIamPermissionsResponse)(nil),
+			signature: "resource,permissions",
+		},
+	}
+
+	for _, method := range methods {
+		reqRpc := builder.RpcTypeImportedMessage(loadMessageDescriptor(method.req), false)
+		resRpc := builder.RpcTypeImportedMessage(loadMessageDescriptor(method.res), false)
+
+		m := builder.NewMethod(method.name, reqRpc, resRpc)
+		m.SetComments(comment(method.comment, ""))
+		if c.WithHTTPOptions {
+			rule := &annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Post{
+					Post: fmt.Sprintf("/v1/{resource=%s}:%s", c.ResourceNameUrlRef(), method.verb),
+				},
+				Body: "*",
+			}
+			m.SetOptions(methodOptions(httpRule(rule), methodSignature(method.signature)))
+		}
+
+		s.service.AddMethod(m)
+	}
+}
+
+// wildcardPattern replaces the variables of pattern by the `-` wildcard, e.g.
+// `organizations/-` for `organizations/{organization}`.
+func wildcardPattern(pattern string) string {
+	return replaceCurly.ReplaceAllString(pattern, "-")
+}
+
+// immutableFieldsComment returns the paragraph of the Update method comment
+// listing the immutable fields of the resource, if any.
+func (s *schemaBuilder) immutableFieldsComment() (string, error) {
+	fields, err := s.cfg.ParseFields()
+	if err != nil {
+		return "", err
+	}
+
+	var names []string
+	for _, f := range fields {
+		if f.Message == "" && f.has(annotations.FieldBehavior_IMMUTABLE) {
+			names = append(names, "`"+f.Name+"`")
+		}
+	}
+
+	switch len(names) {
+	case 0:
+		return "", nil
+	case 1:
+		return "\n\nThe " + names[0] + " field is immutable and cannot appear in the update_mask.", nil
+	default:
+		return "\n\nThe " + strings.Join(names[:len(names)-1], ", ") + " and " + names[len(names)-1] +
+			" fields are immutable and\ncannot appear in the update_mask.", nil
+	}
+}
+
+// buildSearchMethod adds the Search custom method on the collection, listing
+// the resources matching a free-text query with the pagination of List.
+func (s *schemaBuilder) buildSearchMethod() {
+	c := s.cfg
+	if !c.WithSearch {
+		return
+	}
+
+	name := "Search" + c.PluralResource
+
+	// Request
+	req := builder.NewMessage(name + "Request")
+	req.SetComments(comment("Request for "+name+" method.", ""))
+
+	if c.HasParent() {
+		parentField := builder.NewField("parent", builder.FieldTypeString())
+		parentField.SetComments(comment("The resource's parent.", ""))
+		parentField.SetOptions(fieldOptions(required()))
+		req.AddField(parentField)
+	}
+
+	queryField := builder.NewField("query", builder.FieldTypeString())
+	queryField.SetComments(comment("The free-text query. The resources matching all of its terms are returned.", ""))
+	queryField.SetOptions(fieldOptions(required()))
+	req.AddField(queryField)
+
+	addPageFields(req)
+
+	orderByField := builder.NewField("order_by", builder.FieldTypeString())
+	orderByField.SetComments(comment("The order to list results by.", ""))
+	orderByField.SetOptions(fieldOptions(optional()))
+	req.AddField(orderByField)
+
+	// Response
+	res := builder.NewMessage(name + "Response")
+	res.SetComments(comment("Response for "+name+" method.", ""))
+	s.addPageResultFields(res)
+
+	m := builder.NewMethod(name, builder.RpcTypeMessage(req, false), builder.RpcTypeMessage(res, false))
+	m.SetComments(comment("Search the "+c.Resource+" resources matching a free-text query", ""))
+	if c.WithHTTPOptions {
+		parentVar := ""
+		methodSig := "query"
+		if c.HasParent() {
+			parentVar = fmt.Sprintf("{parent=%s}/", c.ParentNameUrlRef())
+			methodSig = "parent,query"
+		}
+
+		rule := &annotations.HttpRule{
+			Pattern: &annotations.HttpRule_Get{
+				Get: fmt.Sprintf("/v1/%s%s:search", parentVar, c.ResourceCollectionIdentifier()),
+			},
+		}
+		m.SetOptions(methodOptions(httpRule(rule), methodSignature(methodSig)))
+	}
+
+	s.file.AddMessage(req)
+	s.file.AddMessage(res)
+	s.service.AddMethod(m)
+}
+
+// buildStateTransitionMethods adds the custom methods moving the resource to
+// another state of its lifecycle, as described by AIP-216.
+func (s *schemaBuilder) buildStateTransitionMethods() error {
+	c := s.cfg
+
+	transitions, err := c.ParseStateTransitions()
+	if err != nil {
+		return err
+	}
+
+	for _, t := range transitions {
+		name := t.Verb + c.Resource
+
+		req := builder.NewMessage(name + "Request")
+		req.SetComments(comment("Request for "+name+" method.", ""))
+
+		nameField := builder.NewField("name", builder.FieldTypeString())
+		nameField.SetComments(comment("The name of the resource to "+strings.ToLower(t.Verb)+".", ""))
+		nameField.SetOptions(fieldOptions(required()))
+		req.AddField(nameField)
+
+		if c.WithValidateOnly {
+			req.AddField(validateOnlyField())
+		}
+
+		if c.WithEtag {
+			etagField := builder.NewField("etag", builder.FieldTypeString())
+			etagField.SetComments(comment("The etag of the resource. If provided, it must match the server's etag\n"+
+				"for the transition to proceed.", ""))
+			etagField.SetOptions(fieldOptions(optional()))
+			req.AddField(etagField)
+		}
+
+		m := builder.NewMethod(name, builder.RpcTypeMessage(req, false), builder.RpcTypeMessage(s.resource, false))
+		m.SetComments(comment(t.Verb+" the "+c.Resource+" resource, moving it to the "+t.State+" state", ""))
+		if c.WithHTTPOptions {
+			rule := &annotations.HttpRule{
+				Pattern: &annotations.HttpRule_Post{
+					Post: fmt.Sprintf("/v1/{name=%s}:%s", c.ResourceNameUrlRef(), strcase.LowerCamelCase(t.Verb)),
+				},
+				Body: "*",
+			}
+			m.SetOptions(methodOptions(httpRule(rule), methodSignature("name")))
+		}
+
+		s.file.AddMessage(req)
+		s.service.AddMethod(m)
+	}
+
+	return nil
+}
+
+// addPageFields adds the page_size and page_token fields to the request of a
+// paginated method.
+func addPageFields(req *builder.MessageBuilder) {
+	pageSizeField := builder.NewField("page_size", builder.FieldTypeInt32())
+	pageSizeField.SetComments(comment("The maximum number of resources to return.", ""))
+	pageSizeField.SetOptions(fieldOptions(optional()))
+	req.AddField(pageSizeField)
+
+	pageTokenField := builder.NewField("page_token", builder.FieldTypeString())
+	pageTokenField.SetComments(comment("The page token to use for pagination. Provide this to retrieve subsequent page", ""))
+	pageTokenField.SetOptions(fieldOptions(optional()))
+	req.AddField(pageTokenField)
+}
+
+// addPageResultFields adds the resources and next_page_token fields to the
+// response of a paginated method.
+func (s *schemaBuilder) addPageResultFields(res *builder.MessageBuilder) {
+	c := s.cfg
+
+	resourceField := builder.NewField(c.PluralResourceSnakeCase(), builder.FieldTypeMessage(s.resource))
+	resourceField.SetRepeated()
+	resourceField.SetComments(comment("The list of "+c.Resource+" resources.", ""))
+	res.AddField(resourceField)
+
+	nextTokenField := builder.NewField("next_page_token", builder.FieldTypeString())
+	nextTokenField.SetComments(comment("The token to retrieve the next page of results, or empty if there are no more results.", ""))
+	res.AddField(nextTokenField)
+}
+
+// addReadFields adds the AIP-157 read_mask or view field to the request of a
+// Get or List method.
+func (s *schemaBuilder) addReadFields(req *builder.MessageBuilder) {
+	if s.cfg.WithReadMask {
+		readMaskField := builder.NewField("read_mask", builder.FieldTypeImportedMessage(loadMessageDescriptor((*fieldmaskpb.FieldMask)(nil))))
+		readMaskField.SetComments(comment("The fields of the resource to return. If empty or `*`, all the fields are\n"+
+			"returned.", ""))
+		readMaskField.SetOptions(fieldOptions(optional()))
+		req.AddField(readMaskField)
+	}
+
+	if s.view != nil {
+		viewField := builder.NewField("view", builder.FieldTypeEnum(s.view))
+		viewField.SetComments(comment("The view of the resource to return.", ""))
+		viewField.SetOptions(fieldOptions(optional()))
+		req.AddField(viewField)
+	}
+}
+
+// validateOnlyField returns the AIP-163 validate_only field of mutating requests.
+func validateOnlyField() *builder.FieldBuilder {
+	f := builder.NewField("validate_only", builder.FieldTypeBool())
+	f.SetComments(comment("If set, validate the request and preview the response, but do not actually\n"+
+		"post it.", ""))
+	f.SetOptions(fieldOptions(optional()))
+	return f
+}
+
 func initPrinter(c *Config) *protoprint.Printer {
 	p := &protoprint.Printer{Compact: c.Compact}
 	return p
@@ -439,9 +1166,12 @@ func fieldOptions(opts ...fOpts) *descriptorpb.FieldOptions {
 	return o
 }
 
+// fieldBehavior adds field behaviors to the options, so that they can be
+// combined, e.g. fieldOptions(required(), immutable()).
 func fieldBehavior(behaviors ...annotations.FieldBehavior) fOpts {
 	return fOptsFn(func(opts *descriptorpb.FieldOptions) {
-		proto.SetExtension(opts, annotations.E_FieldBehavior, behaviors)
+		existing, _ := proto.GetExtension(opts, annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+		proto.SetExtension(opts, annotations.E_FieldBehavior, append(existing, behaviors...))
 	})
 }
 
@@ -461,6 +1191,34 @@ func outputOnly() fOpts {
 	return fieldBehavior(annotations.FieldBehavior_OUTPUT_ONLY)
 }
 
+func inputOnly() fOpts {
+	return fieldBehavior(annotations.FieldBehavior_INPUT_ONLY)
+}
+
+func immutable() fOpts {
+	return fieldBehavior(annotations.FieldBehavior_IMMUTABLE)
+}
+
+func unorderedList() fOpts {
+	return fieldBehavior(annotations.FieldBehavior_UNORDERED_LIST)
+}
+
+func nonEmptyDefault() fOpts {
+	return fieldBehavior(annotations.FieldBehavior_NON_EMPTY_DEFAULT)
+}
+
+func resourceReference(typ string) fOpts {
+	return fOptsFn(func(opts *descriptorpb.FieldOptions) {
+		proto.SetExtension(opts, annotations.E_ResourceReference, &annotations.ResourceReference{Type: typ})
+	})
+}
+
+func fieldInfo(format annotations.FieldInfo_Format) fOpts {
+	return fOptsFn(func(opts *descriptorpb.FieldOptions) {
+		proto.SetExtension(opts, annotations.E_FieldInfo, &annotations.FieldInfo{Format: format})
+	})
+}
+
 func methodOptions(opts ...mOpts) *descriptorpb.MethodOptions {
 	if len(opts) == 0 {
 		return nil
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc/builder"
+	"github.com/stoewer/go-strcase"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/type/color"
+	"google.golang.org/genproto/googleapis/type/date"
+	"google.golang.org/genproto/googleapis/type/decimal"
+	"google.golang.org/genproto/googleapis/type/interval"
+	"google.golang.org/genproto/googleapis/type/latlng"
+	"google.golang.org/genproto/googleapis/type/money"
+	"google.golang.org/genproto/googleapis/type/postaladdress"
+	"google.golang.org/genproto/googleapis/type/timeofday"
+	"google.golang.org/protobuf/runtime/protoiface"
+	"google.golang.org/protobuf/types/known/anypb"
+	"google.golang.org/protobuf/types/known/durationpb"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// customField is a field of the resource declared with --field, as
+// `name=NUMBER:type[:BEHAVIOR+BEHAVIOR...]`, e.g.
+// `region=100:string:REQUIRED+IMMUTABLE`.
+// The type is a scalar, well-known or google.type type, a nested enum or message, possibly
+// `repeated`, or a map, e.g. `repeated string` or `map<string, int64>`, or a
+// reference to another resource by its type, e.g.
+// `ref:billing.acme.com/BillingAccount`. A name prefixed by a message name,
+// e.g. `Spec.replicas=1:int32`, declares a field of that nested message
+// instead. The field number follows the name, from 100 for the resource and
+// from 1 for nested messages: it is never derived from the order of the
+// flags, which would renumber the fields following an inserted or removed one.
+// A description of the field may follow a `#`, e.g.
+// `region=100:string#The region hosting the resource.`, becoming its comment.
+type customField struct {
+	// Message is the nested message of the field, or empty for the resource.
+	Message  string
+	Name     string
+	Number   int32
+	Type     string
+	Repeated bool
+	MapKey   string
+	// Reference is the type of the resource referenced by a string field.
+	Reference string
+	Behaviors []annotations.FieldBehavior
+	// Description is the comment of the field, if any.
+	Description string
+}
+
+// customEnum is an enum nested in the resource declared with --enum, as
+// `Name=VALUE,VALUE...`, e.g. `Tier=BASIC,PREMIUM`. Its zero value is always
+// the `<NAME>_UNSPECIFIED` value.
+type customEnum struct {
+	Name   string
+	Values []string
+}
+
+var (
+	fieldName = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
+	mapType   = regexp.MustCompile(`^map<\s*(\w+)\s*,\s*([\w.]+)\s*>$`)
+	typeName  = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`)
+	// resourceType matches the `{Service Name}/{Type}` resource types of AIP-123.
+	resourceType = regexp.MustCompile(`^[a-z][a-z0-9-]*(\.[a-z][a-z0-9-]*)+/[A-Z][a-zA-Z0-9]*$`)
+)
+
+// standardFields are the names of the fields the generator may add to the
+// resource, which custom fields cannot use.
+var standardFields = map[string]bool{
+	"name": true, "display_name": true, "create_time": true, "update_time": true,
+	"annotations": true, "uid": true, "etag": true, "reconciling": true, "state": true,
+	"labels": true, "expire_time": true, "ttl": true, "revision_id": true,
+	"revision_create_time": true,
+}
+
+// fieldTypes are the scalar, well-known and google.type types of custom
+// fields, by name. The `timestamp` and `duration` shorthands are kept for
+// google.protobuf.Timestamp and google.protobuf.Duration.
+var fieldTypes = map[string]func() *builder.FieldType{
+	"string":   builder.FieldTypeString,
+	"bytes":    builder.FieldTypeBytes,
+	"bool":     builder.FieldTypeBool,
+	"int32":    builder.FieldTypeInt32,
+	"int64":    builder.FieldTypeInt64,
+	"uint32":   builder.FieldTypeUInt32,
+	"uint64":   builder.FieldTypeUInt64,
+	"sint32":   builder.FieldTypeSInt32,
+	"sint64":   builder.FieldTypeSInt64,
+	"fixed32":  builder.FieldTypeFixed32,
+	"fixed64":  builder.FieldTypeFixed64,
+	"sfixed32": builder.FieldTypeSFixed32,
+	"sfixed64": builder.FieldTypeSFixed64,
+	"float":    builder.FieldTypeFloat,
+	"double":   builder.FieldTypeDouble,
+
+	"timestamp":                 importedType((*timestamppb.Timestamp)(nil)),
+	"duration":                  importedType((*durationpb.Duration)(nil)),
+	"google.protobuf.Timestamp": importedType((*timestamppb.Timestamp)(nil)),
+	"google.protobuf.Duration":  importedType((*durationpb.Duration)(nil)),
+	"google.protobuf.Struct":    importedType((*structpb.Struct)(nil)),
+	"google.protobuf.Any":       importedType((*anypb.Any)(nil)),
+
+	"google.type.Money":         importedType((*money.Money)(nil)),
+	"google.type.Date":          importedType((*date.Date)(nil)),
+	"google.type.TimeOfDay":     importedType((*timeofday.TimeOfDay)(nil)),
+	"google.type.LatLng":        importedType((*latlng.LatLng)(nil)),
+	"google.type.PostalAddress": importedType((*postaladdress.PostalAddress)(nil)),
+	"google.type.Interval":      importedType((*interval.Interval)(nil)),
+	"google.type.Color":         importedType((*color.Color)(nil)),
+	"google.type.Decimal":       importedType((*decimal.Decimal)(nil)),
+}
+
+// mapKeyTypes are the types allowed as map keys.
+var mapKeyTypes = map[string]bool{
+	"string": true, "bool": true, "int32": true, "int64": true, "uint32": true, "uint64": true,
+	"sint32": true, "sint64": true, "fixed32": true, "fixed64": true, "sfixed32": true, "sfixed64": true,
+}
+
+func importedType(msg protoiface.MessageV1) func() *builder.FieldType {
+	return func() *builder.FieldType {
+		return builder.FieldTypeImportedMessage(loadMessageDescriptor(msg))
+	}
+}
+
+// ParseEnums parses the nested enums of the resource.
+func (c *Config) ParseEnums() ([]customEnum, error) {
+	var enums []customEnum
+	seen := map[string]bool{}
+	// Values of enums nested in the same message share its scope.
+	values := map[string]bool{}
+	for _, state := range c.States {
+		values[state] = true
+	}
+
+	for _, spec := range c.Enums {
+		name, list, ok := strings.Cut(spec, "=")
+		if !ok || list == "" {
+			return nil, fmt.Errorf("invalid enum %q, must be Name=VALUE,VALUE...", spec)
+		}
+		if !typeName.MatchString(name) {
+			return nil, fmt.Errorf("invalid enum %q, its name must be UpperCamelCase", spec)
+		}
+		if seen[name] || name == "State" && len(c.States) > 0 {
+			return nil, fmt.Errorf("duplicate enum %q", name)
+		}
+		seen[name] = true
+
+		e := customEnum{Name: name}
+		for _, v := range strings.Split(list, ",") {
+			v = strings.TrimSpace(v)
+			if !stateName.MatchString(v) || strings.HasSuffix(v, "UNSPECIFIED") {
+				return nil, fmt.Errorf("invalid enum %q, value %q must be UPPER_SNAKE_CASE and not UNSPECIFIED", spec, v)
+			}
+			if values[v] || v == e.unspecified() {
+				return nil, fmt.Errorf("invalid enum %q, value %q is already declared in the resource", spec, v)
+			}
+			values[v] = true
+			e.Values = append(e.Values, v)
+		}
+		values[e.unspecified()] = true
+
+		enums = append(enums, e)
+	}
+
+	return enums, nil
+}
+
+// unspecified returns the name of the zero value of the enum.
+func (e customEnum) unspecified() string {
+	return strcase.UpperSnakeCase(e.Name) + "_UNSPECIFIED"
+}
+
+// ParseFields parses the custom fields of the resource and of its nested
+// messages.
+func (c *Config) ParseFields() ([]customField, error) {
+	enums, err := c.ParseEnums()
+	if err != nil {
+		return nil, err
+	}
+
+	// Nested types, by name, to resolve the types of the fields.
+	types := map[string]bool{}
+	for _, e := range enums {
+		types[e.Name] = true
+	}
+	messages := map[string]bool{}
+	for _, spec := range c.Fields {
+		msg, _, ok := strings.Cut(spec, ".")
+		if !ok || strings.Contains(msg, ":") || messages[msg] {
+			continue
+		}
+		if !typeName.MatchString(msg) {
+			return nil, fmt.Errorf("invalid field %q, its message name must be UpperCamelCase", spec)
+		}
+		if types[msg] || msg == "State" && len(c.States) > 0 {
+			return nil, fmt.Errorf("invalid field %q, %q is already declared as an enum", spec, msg)
+		}
+		messages[msg] = true
+	}
+	for msg := range messages {
+		types[msg] = true
+	}
+
+	var fields []customField
+	seen := map[string]bool{}
+
+	for _, spec := range c.Fields {
+		decl, description, _ := strings.Cut(spec, "#")
+		name, rest, ok := strings.Cut(decl, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid field %q, must be [Message.]name=NUMBER:type[:BEHAVIOR+...][#description]", spec)
+		}
+		typ, behaviors, _ := strings.Cut(rest, ":")
+
+		f := customField{Type: strings.TrimSpace(typ), Description: strings.TrimSpace(description)}
+		name, number, ok := strings.Cut(name, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid field %q, its number must follow its name, e.g. region=100:string", spec)
+		}
+		n, err := strconv.ParseInt(number, 10, 32)
+		if err != nil || !validFieldNumber(int32(n)) {
+			return nil, fmt.Errorf("invalid field %q, malformed field number %q", spec, number)
+		}
+		f.Number = int32(n)
+		f.Name = name
+		if msg, field, ok := strings.Cut(name, "."); ok {
+			f.Message, f.Name = msg, field
+		}
+		if !fieldName.MatchString(f.Name) {
+			return nil, fmt.Errorf("invalid field %q, its name must be lower_snake_case", spec)
+		}
+		if f.Message == "" && standardFields[f.Name] {
+			return nil, fmt.Errorf("invalid field %q, %q is a standard field of the resource", spec, f.Name)
+		}
+		if f.Message == "" && f.Number < customFieldNumberStart {
+			return nil, fmt.Errorf("invalid field %q, the numbers below %d are reserved to the standard fields", spec, customFieldNumberStart)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate field %q", name)
+		}
+		seen[name] = true
+
+		if t, ok := strings.CutPrefix(f.Type, "repeated "); ok {
+			f.Type, f.Repeated = strings.TrimSpace(t), true
+		}
+		if f.Type == "ref" {
+			f.Reference, behaviors, _ = strings.Cut(behaviors, ":")
+			if !resourceType.MatchString(f.Reference) {
+				return nil, fmt.Errorf("invalid field %q, malformed resource type %q, must be {Service Name}/{Type}, e.g. billing.acme.com/BillingAccount", spec, f.Reference)
+			}
+			f.Type = "string"
+		} else if m := mapType.FindStringSubmatch(f.Type); m != nil && !f.Repeated {
+			f.MapKey, f.Type = m[1], m[2]
+			if !mapKeyTypes[f.MapKey] {
+				return nil, fmt.Errorf("invalid field %q, unsupported map key type %q", spec, f.MapKey)
+			}
+		}
+		if _, ok := fieldTypes[f.Type]; !ok && !types[f.Type] {
+			return nil, fmt.Errorf("invalid field %q, unknown type %q", spec, f.Type)
+		}
+
+		if behaviors != "" {
+			for _, b := range strings.Split(behaviors, "+") {
+				v, ok := annotations.FieldBehavior_value[strings.TrimSpace(b)]
+				if !ok || v == 0 {
+					return nil, fmt.Errorf("invalid field %q, unknown field behavior %q", spec, b)
+				}
+				f.Behaviors = append(f.Behaviors, annotations.FieldBehavior(v))
+			}
+		}
+		if err := f.validateBehaviors(); err != nil {
+			return nil, fmt.Errorf("invalid field %q, %v", spec, err)
+		}
+
+		fields = append(fields, f)
+	}
+
+	if err := c.checkFieldNumbers(fields); err != nil {
+		return nil, err
+	}
+
+	return fields, nil
+}
+
+// checkFieldNumbers checks that the custom fields use distinct numbers within
+// their message, and none reserved by the resource.
+func (c *Config) checkFieldNumbers(fields []customField) error {
+	ranges, names, err := c.ParseReserved()
+	if err != nil {
+		return err
+	}
+
+	// Numbers in use, by message.
+	used := map[string]map[int32]string{}
+	for _, f := range fields {
+		if used[f.Message] == nil {
+			used[f.Message] = map[int32]string{}
+		}
+		if other, ok := used[f.Message][f.Number]; ok {
+			return fmt.Errorf("invalid field %q, its number %d is already used by %q", f.Name, f.Number, other)
+		}
+		used[f.Message][f.Number] = f.Name
+
+		if f.Message != "" {
+			continue
+		}
+		if slices.Contains(names, f.Name) {
+			return fmt.Errorf("invalid field %q, its name is reserved", f.Name)
+		}
+		for _, r := range ranges {
+			if r.contains(f.Number) {
+				return fmt.Errorf("invalid field %q, its number %d is reserved", f.Name, f.Number)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f customField) has(behavior annotations.FieldBehavior) bool {
+	for _, b := range f.Behaviors {
+		if b == behavior {
+			return true
+		}
+	}
+	return false
+}
+
+// validateBehaviors rejects the combinations of field behaviors contradicting
+// each other as described by AIP-203.
+func (f customField) validateBehaviors() error {
+	conflicts := [][2]annotations.FieldBehavior{
+		{annotations.FieldBehavior_REQUIRED, annotations.FieldBehavior_OPTIONAL},
+		{annotations.FieldBehavior_REQUIRED, annotations.FieldBehavior_OUTPUT_ONLY},
+		{annotations.FieldBehavior_OPTIONAL, annotations.FieldBehavior_OUTPUT_ONLY},
+		{annotations.FieldBehavior_INPUT_ONLY, annotations.FieldBehavior_OUTPUT_ONLY},
+		{annotations.FieldBehavior_IMMUTABLE, annotations.FieldBehavior_INPUT_ONLY},
+	}
+	for _, c := range conflicts {
+		if f.has(c[0]) && f.has(c[1]) {
+			return fmt.Errorf("%s and %s are mutually exclusive", c[0], c[1])
+		}
+	}
+
+	if f.has(annotations.FieldBehavior_IDENTIFIER) {
+		return fmt.Errorf("IDENTIFIER is reserved to the name field")
+	}
+	if f.has(annotations.FieldBehavior_UNORDERED_LIST) && !f.Repeated {
+		return fmt.Errorf("UNORDERED_LIST only applies to repeated fields")
+	}
+
+	return nil
+}
+
+// addCustomFields adds the nested enums and messages and the custom fields to
+// the resource message b.
+func addCustomFields(c *Config, b *builder.MessageBuilder) error {
+	enums, err := c.ParseEnums()
+	if err != nil {
+		return err
+	}
+	fields, err := c.ParseFields()
+	if err != nil {
+		return err
+	}
+
+	types := map[string]func() *builder.FieldType{}
+	for name, t := range fieldTypes {
+		types[name] = t
+	}
+
+	for _, e := range enums {
+		eb := builder.NewEnum(e.Name)
+		eb.SetComments(comment(e.Name+" of the "+c.Resource+" resource.", ""))
+
+		unspecified := builder.NewEnumValue(e.unspecified()).SetNumber(0)
+		unspecified.SetComments(comment("Unspecified.", ""))
+		eb.AddValue(unspecified)
+		for i, v := range e.Values {
+			eb.AddValue(builder.NewEnumValue(v).SetNumber(int32(i + 1)))
+		}
+
+		b.AddNestedEnum(eb)
+		types[e.Name] = func() *builder.FieldType { return builder.FieldTypeEnum(eb) }
+	}
+
+	messages := map[string]*builder.MessageBuilder{}
+	for _, f := range fields {
+		if f.Message == "" || messages[f.Message] != nil {
+			continue
+		}
+		mb := builder.NewMessage(f.Message)
+		mb.SetComments(comment(f.Message+" of the "+c.Resource+" resource.", ""))
+		b.AddNestedMessage(mb)
+		messages[f.Message] = mb
+		types[f.Message] = func() *builder.FieldType { return builder.FieldTypeMessage(mb) }
+	}
+
+	for _, f := range fields {
+		if f.Message == "" {
+			b.AddField(f.builder(types))
+		} else {
+			messages[f.Message].AddField(f.builder(types))
+		}
+	}
+
+	return nil
+}
+
+// referenceFormat returns the comment line documenting the format of the
+// references to resources of type typ.
+func referenceFormat(typ string) string {
+	return "Format: the name of a `" + typ + "` resource."
+}
+
+// builder returns the builder of the field, resolving its type in types.
+func (f customField) builder(types map[string]func() *builder.FieldType) *builder.FieldBuilder {
+	var b *builder.FieldBuilder
+	if f.MapKey != "" {
+		b = builder.NewMapField(f.Name, types[f.MapKey](), types[f.Type]())
+	} else {
+		b = builder.NewField(f.Name, types[f.Type]())
+		if f.Repeated {
+			b.SetRepeated()
+		}
+	}
+	b.SetNumber(f.Number)
+
+	// Only the given description and the format of references are commented,
+	// rather than a comment paraphrasing the name of the field.
+	var lines []string
+	if f.Description != "" {
+		lines = append(lines, f.Description)
+	}
+	if f.Reference != "" {
+		lines = append(lines, referenceFormat(f.Reference))
+	}
+	if len(lines) > 0 {
+		b.SetComments(comment(strings.Join(lines, "\n"), ""))
+	}
+
+	behaviors := f.Behaviors
+	if len(behaviors) == 0 {
+		behaviors = []annotations.FieldBehavior{annotations.FieldBehavior_OPTIONAL}
+	}
+	opts := []fOpts{fieldBehavior(behaviors...)}
+	if f.Reference != "" {
+		opts = append(opts, resourceReference(f.Reference))
+	}
+	b.SetOptions(fieldOptions(opts...))
+
+	return b
+}
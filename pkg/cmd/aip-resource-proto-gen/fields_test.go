@@ -0,0 +1,116 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+)
+
+func TestParseFields(t *testing.T) {
+	tests := []struct {
+		fields []string
+		want   []customField
+	}{
+		{nil, nil},
+		{
+			[]string{"region=100:string:REQUIRED+IMMUTABLE", "replicas=101:int32"},
+			[]customField{
+				{Name: "region", Number: 100, Type: "string", Behaviors: []annotations.FieldBehavior{annotations.FieldBehavior_REQUIRED, annotations.FieldBehavior_IMMUTABLE}},
+				{Name: "replicas", Number: 101, Type: "int32"},
+			},
+		},
+		{
+			// The numbers do not depend on the order of the fields.
+			[]string{"b=105:string", "a=100:string"},
+			[]customField{
+				{Name: "b", Number: 105, Type: "string"},
+				{Name: "a", Number: 100, Type: "string"},
+			},
+		},
+		{
+			[]string{"tags=100:repeated string:UNORDERED_LIST", "limits=101:map<string, int64>"},
+			[]customField{
+				{Name: "tags", Number: 100, Type: "string", Repeated: true, Behaviors: []annotations.FieldBehavior{annotations.FieldBehavior_UNORDERED_LIST}},
+				{Name: "limits", Number: 101, Type: "int64", MapKey: "string"},
+			},
+		},
+		{
+			[]string{"billing_account=100:ref:billing.acme.com/BillingAccount:REQUIRED"},
+			[]customField{
+				{Name: "billing_account", Number: 100, Type: "string", Reference: "billing.acme.com/BillingAccount", Behaviors: []annotations.FieldBehavior{annotations.FieldBehavior_REQUIRED}},
+			},
+		},
+		{
+			// The description may hold colons.
+			[]string{"region=100:string:IMMUTABLE#The region, e.g. eu:west.", "account=101:ref:billing.acme.com/BillingAccount# The paying account. "},
+			[]customField{
+				{Name: "region", Number: 100, Type: "string", Behaviors: []annotations.FieldBehavior{annotations.FieldBehavior_IMMUTABLE}, Description: "The region, e.g. eu:west."},
+				{Name: "account", Number: 101, Type: "string", Reference: "billing.acme.com/BillingAccount", Description: "The paying account."},
+			},
+		},
+		{
+			// Nested messages are numbered from 1, independently of the resource.
+			[]string{"spec=100:Spec", "Spec.disk_size=1:int64", "Spec.zones=2:repeated google.type.LatLng", "expire=101:timestamp"},
+			[]customField{
+				{Name: "spec", Number: 100, Type: "Spec"},
+				{Message: "Spec", Name: "disk_size", Number: 1, Type: "int64"},
+				{Message: "Spec", Name: "zones", Number: 2, Type: "google.type.LatLng", Repeated: true},
+				{Name: "expire", Number: 101, Type: "timestamp"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		c := &Config{Fields: tt.fields}
+		got, err := c.ParseFields()
+		if err != nil {
+			t.Errorf("ParseFields(%q) = %v", tt.fields, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseFields(%q) = %+v, want %+v", tt.fields, got, tt.want)
+		}
+	}
+}
+
+func TestParseFieldsErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		fields   []string
+		enums    []string
+		states   []string
+		reserved []string
+	}{
+		{"missing type", []string{"region=100"}, nil, nil, nil},
+		{"missing number", []string{"region:string"}, nil, nil, nil},
+		{"malformed number", []string{"region=abc:string"}, nil, nil, nil},
+		{"out of range number", []string{"region=19000:string"}, nil, nil, nil},
+		{"number of a standard field", []string{"region=5:string"}, nil, nil, nil},
+		{"name not lower_snake_case", []string{"Region=100:string"}, nil, nil, nil},
+		{"standard field", []string{"display_name=100:string"}, nil, nil, nil},
+		{"duplicate field", []string{"region=100:string", "region=101:int32"}, nil, nil, nil},
+		{"duplicate number", []string{"a=100:string", "b=100:string"}, nil, nil, nil},
+		{"duplicate nested number", []string{"spec=100:Spec", "Spec.a=1:string", "Spec.b=1:string"}, nil, nil, nil},
+		{"reserved number", []string{"region=112:string"}, nil, nil, []string{"110-119"}},
+		{"reserved name", []string{"old_field=100:string"}, nil, nil, []string{"old_field"}},
+		{"unknown type", []string{"region=100:varchar"}, nil, nil, nil},
+		{"unknown behavior", []string{"region=100:string:MANDATORY"}, nil, nil, nil},
+		{"conflicting behaviors", []string{"region=100:string:REQUIRED+OUTPUT_ONLY"}, nil, nil, nil},
+		{"identifier", []string{"region=100:string:IDENTIFIER"}, nil, nil, nil},
+		{"unordered singular field", []string{"region=100:string:UNORDERED_LIST"}, nil, nil, nil},
+		{"malformed reference", []string{"account=100:ref:BillingAccount"}, nil, nil, nil},
+		{"unsupported map key", []string{"limits=100:map<double, string>"}, nil, nil, nil},
+		{"message not UpperCamelCase", []string{"spec_v2.size=1:int32"}, nil, nil, nil},
+		{"message declared as an enum", []string{"Tier.size=1:int32"}, []string{"Tier=BASIC"}, nil, nil},
+		{"message declared as the state", []string{"State.size=1:int32"}, nil, []string{"ACTIVE"}, nil},
+		{"invalid enum", []string{"tier=100:Tier"}, []string{"tier=BASIC"}, nil, nil},
+	}
+
+	for _, tt := range tests {
+		c := &Config{Fields: tt.fields, Enums: tt.enums, States: tt.states, Reserved: tt.reserved}
+		if got, err := c.ParseFields(); err == nil {
+			t.Errorf("%s: ParseFields(%q) = %+v, want an error", tt.name, tt.fields, got)
+		}
+	}
+}
@@ -0,0 +1,700 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/stoewer/go-strcase"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/descriptorpb"
+)
+
+func newImportCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "import <file.proto>",
+		Short: "Infer the flags generating the resources of an existing protobuf IDL file",
+		Long: "Parse an existing protobuf IDL file and print, for each of its resources, the command\n" +
+			"generating it: its parent, standard and custom fields and methods. What cannot be\n" +
+			"inferred, and the incompatibilities between the file and the generated one, are\n" +
+			"reported as comments.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			p := protoparse.Parser{
+				ImportPaths:           []string{filepath.Dir(args[0])},
+				LookupImport:          desc.LoadFileDescriptor,
+				IncludeSourceCodeInfo: true,
+			}
+			fds, err := p.ParseFiles(filepath.Base(args[0]))
+			if err != nil {
+				return fmt.Errorf("failed to parse %s: %v", args[0], err)
+			}
+			fd := fds[0]
+
+			found := false
+			for _, md := range fd.GetMessageTypes() {
+				if resourceDescriptor(md.AsDescriptorProto()) == nil {
+					continue
+				}
+				if found {
+					fmt.Fprintln(cmd.OutOrStdout())
+				}
+				found = true
+
+				imp := &importer{file: fd, resource: md}
+				if err := imp.run(cmd.OutOrStdout()); err != nil {
+					return err
+				}
+			}
+			if !found {
+				return fmt.Errorf("no resource message found in %s", args[0])
+			}
+
+			return nil
+		},
+	}
+}
+
+// importer infers the config generating a resource of an existing file by
+// setting the flags of the generator, so that only those differing from their
+// defaults are printed.
+type importer struct {
+	file     *desc.FileDescriptor
+	resource *desc.MessageDescriptor
+
+	cfg      Config
+	flags    *pflag.FlagSet
+	warnings []string
+}
+
+func (imp *importer) set(name, value string) {
+	if err := imp.flags.Set(name, value); err != nil {
+		panic(err)
+	}
+}
+
+func (imp *importer) warn(format string, args ...any) {
+	imp.warnings = append(imp.warnings, fmt.Sprintf(format, args...))
+}
+
+func (imp *importer) run(w io.Writer) error {
+	imp.flags = pflag.NewFlagSet("import", pflag.ContinueOnError)
+	imp.cfg.registerFlags(imp.flags)
+
+	md := imp.resource
+	rd := resourceDescriptor(md.AsDescriptorProto())
+
+	service, kind, _ := strings.Cut(rd.GetType(), "/")
+	if kind != md.GetName() {
+		imp.warn("the resource type %s differs from its message %s", rd.GetType(), md.GetName())
+	}
+	imp.set("package", imp.file.GetPackage())
+	imp.set("service", service)
+	if !imp.file.IsProto3() {
+		imp.set("syntax", "proto2")
+	}
+
+	imp.inferPattern(rd)
+	imp.inferFields()
+	imp.inferMethods()
+
+	if err := imp.cfg.complete(md.GetName()); err != nil {
+		return fmt.Errorf("invalid config inferred for %s: %v", md.GetFullyQualifiedName(), err)
+	}
+	imp.inferNumbering()
+	imp.compare()
+
+	fmt.Fprintf(w, "# %s\n", md.GetFullyQualifiedName())
+	for _, warning := range imp.warnings {
+		fmt.Fprintf(w, "# warning: %s\n", warning)
+	}
+	fmt.Fprint(w, "aip-resource-proto-gen")
+	imp.flags.VisitAll(func(f *pflag.Flag) {
+		if !f.Changed {
+			return
+		}
+		switch f.Value.Type() {
+		case "bool":
+			if f.Value.String() == "true" {
+				fmt.Fprintf(w, " \\\n  --%s", f.Name)
+			} else {
+				fmt.Fprintf(w, " \\\n  --%s=%s", f.Name, f.Value)
+			}
+		case "stringSlice":
+			fmt.Fprintf(w, " \\\n  --%s %s", f.Name, shellQuote(strings.Join(f.Value.(pflag.SliceValue).GetSlice(), ",")))
+		case "stringArray":
+			for _, v := range f.Value.(pflag.SliceValue).GetSlice() {
+				fmt.Fprintf(w, " \\\n  --%s %s", f.Name, shellQuote(v))
+			}
+		default:
+			fmt.Fprintf(w, " \\\n  --%s %s", f.Name, shellQuote(f.Value.String()))
+		}
+	})
+	fmt.Fprintf(w, " \\\n  %s\n", md.GetName())
+
+	return nil
+}
+
+// inferPattern infers the parent and the plural of the resource from its
+// pattern or, lacking one as in the files generated by the first releases,
+// from the HTTP bindings of its methods.
+func (imp *importer) inferPattern(rd *annotations.ResourceDescriptor) {
+	var pattern string
+	if patterns := rd.GetPattern(); len(patterns) > 0 {
+		if len(patterns) > 1 {
+			imp.warn("only the first of the patterns of the resource is kept")
+		}
+		pattern = patterns[0]
+	} else if pattern = imp.httpPattern(); pattern == "" {
+		imp.warn("the resource has no pattern, nor a Get or Delete HTTP binding to infer it from")
+		return
+	}
+
+	segments := strings.Split(pattern, "/")
+	if len(segments) < 2 || len(segments)%2 != 0 {
+		imp.warn("the pattern %s is not made of collection and ID pairs", pattern)
+		return
+	}
+	if len(segments) > 2 {
+		imp.set("resource-parent", strings.Join(segments[:len(segments)-2], "/"))
+	}
+
+	plural := rd.GetPlural()
+	if plural == "" {
+		plural = segments[len(segments)-2]
+	}
+	if plural := strcase.UpperCamelCase(plural); plural != imp.resource.GetName()+"s" {
+		imp.set("resource-plural", plural)
+	}
+}
+
+// httpPattern returns the pattern of the resource as found in the `{name=...}`
+// variable of the HTTP binding of its Get or Delete method, e.g.
+// `organizations/{organization}/projects/{project}` for
+// `/v1/{name=organizations/*/projects/*}`, or an empty string if there is none.
+func (imp *importer) httpPattern() string {
+	r := imp.resource.GetName()
+	for _, sd := range imp.file.GetServices() {
+		for _, name := range []string{"Get" + r, "Delete" + r} {
+			m := sd.FindMethodByName(name)
+			if m == nil {
+				continue
+			}
+			rule := methodHTTPRule(m.AsMethodDescriptorProto())
+			if rule == nil {
+				continue
+			}
+
+			_, path := httpRulePath(rule)
+			_, template, ok := strings.Cut(path, "{name=")
+			template, _, ok2 := strings.Cut(template, "}")
+			segments := strings.Split(template, "/")
+			if !ok || !ok2 || len(segments)%2 != 0 {
+				continue
+			}
+			for i := 0; i < len(segments); i += 2 {
+				if segments[i] == "*" || segments[i+1] != "*" {
+					segments = nil
+					break
+				}
+				segments[i+1] = "{" + singularOf(segments[i]) + "}"
+			}
+			if segments != nil {
+				return strings.Join(segments, "/")
+			}
+		}
+	}
+	return ""
+}
+
+// singularOf returns the singular of a collection identifier in snake case,
+// e.g. `policy` for `policies`, naming the variable of its IDs.
+func singularOf(collection string) string {
+	name := strcase.SnakeCase(collection)
+	if stem, ok := strings.CutSuffix(name, "ies"); ok {
+		return stem + "y"
+	}
+	return strings.TrimSuffix(name, "s")
+}
+
+func (imp *importer) inferFields() {
+	md := imp.resource
+
+	has := func(name string) bool { return md.FindFieldByName(name) != nil }
+	if !has("display_name") {
+		imp.set("resource-with-display-name", "false")
+	}
+	if !has("create_time") || !has("update_time") {
+		imp.set("resource-with-timestamps", "false")
+	}
+	if !has("annotations") {
+		imp.set("resource-with-annotations", "false")
+	}
+	for name, flag := range map[string]string{
+		"uid":         "resource-with-uid",
+		"etag":        "resource-with-etag",
+		"reconciling": "resource-with-reconciling",
+		"labels":      "resource-with-labels",
+		"expire_time": "resource-with-expiration",
+		"revision_id": "with-revisions",
+	} {
+		if has(name) {
+			imp.set(flag, "true")
+		}
+	}
+
+	// Names of the nested types, which fields may refer to.
+	nested := map[string]bool{}
+	for _, ed := range md.GetNestedEnumTypes() {
+		nested[ed.GetName()] = true
+	}
+	for _, nmd := range md.GetNestedMessageTypes() {
+		nested[nmd.GetName()] = !nmd.IsMapEntry()
+	}
+
+	for _, ed := range md.GetNestedEnumTypes() {
+		imp.inferEnum(ed)
+	}
+	for _, nmd := range md.GetNestedMessageTypes() {
+		if nmd.IsMapEntry() {
+			continue
+		}
+		if len(nmd.GetNestedMessageTypes()) > 0 || len(nmd.GetNestedEnumTypes()) > 0 {
+			imp.warn("the types nested in %s are not supported", nmd.GetName())
+		}
+		for _, f := range nmd.GetFields() {
+			imp.inferField(f, nmd.GetName()+".", nested)
+		}
+	}
+
+	for _, f := range md.GetFields() {
+		if _, ok := resourceFieldNumbers[f.GetName()]; ok {
+			continue
+		}
+		if oneof := f.GetOneOf(); oneof != nil && !oneof.IsSynthetic() {
+			imp.warn("the field %s is kept out of the oneof %s", f.GetName(), oneof.GetName())
+		}
+		imp.inferField(f, "", nested)
+	}
+
+	for _, r := range md.AsDescriptorProto().GetReservedRange() {
+		if r.GetEnd()-1 == r.GetStart() {
+			imp.set("reserved", strconv.Itoa(int(r.GetStart())))
+		} else {
+			imp.set("reserved", fmt.Sprintf("%d-%d", r.GetStart(), r.GetEnd()-1))
+		}
+	}
+	for _, name := range md.AsDescriptorProto().GetReservedName() {
+		imp.set("reserved", name)
+	}
+}
+
+func (imp *importer) inferEnum(ed *desc.EnumDescriptor) {
+	var values []string
+	for _, v := range ed.GetValues() {
+		if v.GetNumber() == 0 {
+			if !strings.HasSuffix(v.GetName(), "UNSPECIFIED") {
+				imp.warn("the zero value %s of %s is replaced by an UNSPECIFIED one", v.GetName(), ed.GetName())
+			}
+			continue
+		}
+		values = append(values, v.GetName())
+	}
+
+	if ed.GetName() == "State" && imp.resource.FindFieldByName("state") != nil {
+		imp.set("state", strings.Join(values, ","))
+		return
+	}
+	imp.set("enum", ed.GetName()+"="+strings.Join(values, ","))
+}
+
+// inferField adds the custom field f, whose name is prefixed by that of its
+// nested message, if any.
+func (imp *importer) inferField(f *desc.FieldDescriptor, prefix string, nested map[string]bool) {
+	typ, ok := imp.fieldType(f, nested)
+	if !ok {
+		imp.warn("the field %s%s has the unsupported type %s", prefix, f.GetName(), f.AsFieldDescriptorProto().GetTypeName())
+		return
+	}
+
+	if prefix == "" && f.GetNumber() < customFieldNumberStart {
+		imp.warn("the field %s is numbered %d, below the %d of the custom fields", f.GetName(), f.GetNumber(), customFieldNumberStart)
+		return
+	}
+
+	spec := prefix + f.GetName() + "=" + strconv.Itoa(int(f.GetNumber())) + ":" + typ
+	var behaviors []string
+	for _, b := range fieldBehaviors(f.AsFieldDescriptorProto()) {
+		behaviors = append(behaviors, b.String())
+	}
+	if len(behaviors) > 0 && !slices.Equal(behaviors, []string{annotations.FieldBehavior_OPTIONAL.String()}) {
+		spec += ":" + strings.Join(behaviors, "+")
+	}
+	if description := fieldDescription(f); description != "" {
+		spec += "#" + description
+	}
+
+	imp.set("field", spec)
+}
+
+// fieldDescription returns the leading comment of the custom field f on a
+// single line, without the format of references the generator adds.
+func fieldDescription(f *desc.FieldDescriptor) string {
+	var lines []string
+	for _, line := range strings.Split(f.GetSourceInfo().GetLeadingComments(), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "Format: the name of a `") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, " ")
+}
+
+// fieldType returns the type of a custom field as declared with --field.
+func (imp *importer) fieldType(f *desc.FieldDescriptor, nested map[string]bool) (string, bool) {
+	if f.IsMap() {
+		key, ok1 := imp.fieldType(f.GetMapKeyType(), nested)
+		value, ok2 := imp.fieldType(f.GetMapValueType(), nested)
+		return "map<" + key + ", " + value + ">", ok1 && ok2
+	}
+
+	var typ string
+	switch {
+	case f.GetMessageType() != nil:
+		typ = imp.namedType(f.GetMessageType().GetFullyQualifiedName(), nested)
+	case f.GetEnumType() != nil:
+		typ = imp.namedType(f.GetEnumType().GetFullyQualifiedName(), nested)
+	default:
+		typ = strings.ToLower(strings.TrimPrefix(f.GetType().String(), "TYPE_"))
+	}
+	if typ == "" {
+		return "", false
+	}
+
+	opts := &descriptorpb.FieldOptions{}
+	decodeOptions(f.GetFieldOptions(), opts)
+	if ref, _ := proto.GetExtension(opts, annotations.E_ResourceReference).(*annotations.ResourceReference); ref.GetType() != "" && typ == "string" {
+		typ = "ref:" + ref.GetType()
+	}
+
+	if f.IsRepeated() {
+		typ = "repeated " + typ
+	}
+	return typ, true
+}
+
+// namedType returns the name of a message or enum type as declared with
+// --field, or an empty string if it is not supported.
+func (imp *importer) namedType(fullName string, nested map[string]bool) string {
+	switch fullName {
+	case "google.protobuf.Timestamp":
+		return "timestamp"
+	case "google.protobuf.Duration":
+		return "duration"
+	}
+	if _, ok := fieldTypes[fullName]; ok {
+		return fullName
+	}
+	if name, ok := strings.CutPrefix(fullName, imp.resource.GetFullyQualifiedName()+"."); ok && nested[name] {
+		return name
+	}
+	return ""
+}
+
+// transitionComment extracts the target state of a state-transition method
+// from its generated comment.
+var transitionComment = regexp.MustCompile(`moving it to the (\w+) state`)
+
+func (imp *importer) inferMethods() {
+	r := imp.resource.GetName()
+	plural := r + "s"
+	if p := imp.flags.Lookup("resource-plural"); p.Changed {
+		plural = p.Value.String()
+	}
+
+	methods := map[string]*desc.MethodDescriptor{}
+	for _, sd := range imp.file.GetServices() {
+		for _, m := range sd.GetMethods() {
+			methods[m.GetName()] = m
+		}
+	}
+	method := func(names ...string) *desc.MethodDescriptor {
+		for _, name := range names {
+			if m := methods[name]; m != nil {
+				delete(methods, name)
+				return m
+			}
+		}
+		return nil
+	}
+
+	var (
+		letters string
+		seen    []*desc.MethodDescriptor
+	)
+	for _, m := range []struct {
+		letter string
+		names  []string
+	}{
+		{"c", []string{"Create" + r}},
+		{"r", []string{"Get" + r}},
+		{"u", []string{"Update" + r}},
+		{"d", []string{"Delete" + r}},
+		{"l", []string{"List" + r, "List" + plural}},
+	} {
+		if md := method(m.names...); md != nil {
+			letters += m.letter
+			seen = append(seen, md)
+		}
+	}
+	if letters != "crudl" {
+		imp.set("methods", letters)
+	}
+
+	for name, flag := range map[string]string{
+		"Search" + plural:         "with-search",
+		"Watch" + r:               "with-watch",
+		"GetIamPolicy":            "with-iam",
+		"Watch" + plural:          "",
+		"Commit" + r:              "with-revisions",
+		"Rollback" + r:            "",
+		"Delete" + r + "Revision": "",
+		"List" + r + "Revisions":  "",
+		"SetIamPolicy":            "",
+		"TestIamPermissions":      "",
+	} {
+		if md := method(name); md != nil {
+			seen = append(seen, md)
+			if flag != "" {
+				imp.set(flag, "true")
+			}
+		}
+	}
+
+	var transitions []string
+	for name, m := range methods {
+		verb, ok := strings.CutSuffix(name, r)
+		if !ok || !verbName.MatchString(verb) || m.GetOutputType() != imp.resource {
+			continue
+		}
+		seen = append(seen, m)
+		if state := imp.transitionState(verb, m); state != "" {
+			transitions = append(transitions, verb+"="+state)
+		}
+	}
+	if len(transitions) > 0 {
+		slices.Sort(transitions)
+		imp.set("state-transitions", strings.Join(transitions, ","))
+	}
+
+	withHTTP := false
+	for _, m := range seen {
+		withHTTP = withHTTP || methodHTTPRule(m.AsMethodDescriptorProto()) != nil
+	}
+	if len(seen) > 0 && !withHTTP {
+		imp.set("with-http-options", "false")
+	}
+
+	imp.inferRequestFields(r, plural)
+}
+
+// transitionState returns the target state of the state-transition method m,
+// the state of the resource whose name best matches the custom verb of its
+// HTTP binding, e.g. SUSPENDED for `:suspend`, or else its own verb. The
+// generated comment of the method settles the verbs matching no state or
+// several ones, e.g. Resume moving to ACTIVE, which are otherwise reported.
+func (imp *importer) transitionState(verb string, m *desc.MethodDescriptor) string {
+	if rule := methodHTTPRule(m.AsMethodDescriptorProto()); rule != nil {
+		_, path := httpRulePath(rule)
+		if i := strings.LastIndex(path, ":"); i > strings.LastIndex(path, "}") {
+			verb = path[i+1:]
+		}
+	}
+
+	states := matchStates(verb, imp.cfg.States)
+	if len(states) == 1 {
+		return states[0]
+	}
+	if s := transitionComment.FindStringSubmatch(m.GetSourceInfo().GetLeadingComments()); s != nil && slices.Contains(imp.cfg.States, s[1]) {
+		return s[1]
+	}
+
+	if len(states) == 0 {
+		imp.warn("the target state of the %s method cannot be inferred from its verb %s", m.GetName(), verb)
+	} else {
+		imp.warn("the target state of the %s method is ambiguous, one of %s", m.GetName(), strings.Join(states, ", "))
+	}
+	return ""
+}
+
+// matchStates returns the states sharing the longest prefix with the verb, of
+// at least four letters or of the whole verb, e.g. SUSPENDED for suspend.
+func matchStates(verb string, states []string) []string {
+	verb = strings.ToUpper(strcase.SnakeCase(verb))
+
+	var (
+		matches []string
+		longest int
+	)
+	for _, state := range states {
+		n := 0
+		for n < len(verb) && n < len(state) && verb[n] == state[n] {
+			n++
+		}
+		switch {
+		case n < 4 && n < len(verb), n < longest:
+		case n > longest:
+			matches, longest = []string{state}, n
+		default:
+			matches = append(matches, state)
+		}
+	}
+	return matches
+}
+
+// inferRequestFields infers the options of the standard methods from the
+// fields of their request and response messages.
+func (imp *importer) inferRequestFields(r, plural string) {
+	message := func(names ...string) *desc.MessageDescriptor {
+		for _, name := range names {
+			if md := imp.file.FindMessage(imp.file.GetPackage() + "." + name); md != nil {
+				return md
+			}
+		}
+		return nil
+	}
+	has := func(md *desc.MessageDescriptor, field string) bool {
+		return md != nil && md.FindFieldByName(field) != nil
+	}
+
+	get := message("Get" + r + "Request")
+	list := message("List"+r+"Request", "List"+plural+"Request")
+	listRes := message("List"+r+"Response", "List"+plural+"Response")
+	create := message("Create" + r + "Request")
+	update := message("Update" + r + "Request")
+	del := message("Delete" + r + "Request")
+
+	if list != nil {
+		if !has(list, "filter") {
+			imp.set("with-list-filter", "false")
+		}
+		if !has(list, "order_by") {
+			imp.set("with-list-order-by", "false")
+		}
+		if has(list, "skip") {
+			imp.set("with-list-skip", "true")
+		}
+		if parent := list.FindFieldByName("parent"); parent != nil && strings.Contains(parent.GetSourceInfo().GetLeadingComments(), "wildcard") {
+			imp.set("with-list-wildcard-parent", "true")
+		}
+	}
+	if has(listRes, "total_size") {
+		imp.set("with-list-total-size", "true")
+	}
+	if has(listRes, "unreachable") {
+		imp.set("with-list-unreachable", "true")
+	}
+
+	if has(get, "read_mask") || has(list, "read_mask") {
+		imp.set("with-read-mask", "true")
+	}
+	if has(get, "view") || has(list, "view") {
+		imp.set("with-view", "true")
+	}
+
+	if create != nil {
+		if id := create.FindFieldByName(strcase.SnakeCase(r) + "_id"); id != nil &&
+			slices.Contains(fieldBehaviors(id.AsFieldDescriptorProto()), annotations.FieldBehavior_REQUIRED) {
+			imp.set("resource-id-required", "true")
+		}
+	}
+
+	if update != nil {
+		if !has(update, "update_mask") {
+			imp.set("with-update-field-mask", "false")
+		}
+		if !has(update, "allow_missing") {
+			imp.set("with-update-allow-missing", "false")
+		}
+	}
+	if del != nil {
+		if !has(del, "allow_missing") {
+			imp.set("with-delete-allow-missing", "false")
+		}
+		if has(del, "force") {
+			imp.warn("the child collections of the resource cannot be inferred, declare them with --resource-children")
+		}
+	}
+
+	for _, md := range []*desc.MessageDescriptor{create, update, del} {
+		if has(md, "request_id") {
+			imp.set("with-request-id", "true")
+		}
+		if has(md, "validate_only") {
+			imp.set("with-validate-only", "true")
+		}
+	}
+}
+
+// inferNumbering sets --legacy-field-numbers when a standard field of the first
+// releases is not numbered with its fixed number, as in the files they
+// generated with some of these fields disabled.
+func (imp *importer) inferNumbering() {
+	numbers, err := imp.cfg.messageFieldNumbers()
+	if err != nil {
+		return
+	}
+	numbers[imp.cfg.Resource] = resourceFieldNumbers
+
+	for message, fields := range imp.cfg.firstReleaseFields() {
+		md := imp.file.FindMessage(imp.file.GetPackage() + "." + message)
+		if md == nil {
+			continue
+		}
+		for _, name := range fields {
+			if f := md.FindFieldByName(name); f != nil && f.GetNumber() != numbers[message][name] {
+				imp.set("legacy-field-numbers", "true")
+				return
+			}
+		}
+	}
+}
+
+// compare reports the incompatibilities between the file and the one generated
+// with the inferred config. The elements of the file missing from the
+// generated one, like the other resources of the file, are left out.
+func (imp *importer) compare() {
+	s := &schemaBuilder{cfg: &imp.cfg}
+	fd, err := s.Build()
+	if err != nil {
+		imp.warn("the file cannot be generated: %v", err)
+		return
+	}
+
+	for _, c := range compareFiles(imp.file.AsFileDescriptorProto(), fd.AsFileDescriptorProto()) {
+		if strings.HasSuffix(c.Message, " removed") && c.Message != "field removed" && !strings.HasPrefix(c.Message, "resource") {
+			continue
+		}
+		imp.warn("%s", c)
+	}
+}
+
+// shellQuote quotes s for a POSIX shell if needed.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("_-./=:,+@", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
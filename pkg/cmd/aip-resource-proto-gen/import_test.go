@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/jhump/protoreflect/desc/protoparse"
+)
+
+// importFile runs the importer on the resource of the proto file path and
+// returns it, along with its output.
+func importFile(t *testing.T, path string, accessor protoparse.FileAccessor) (*importer, string) {
+	t.Helper()
+
+	p := protoparse.Parser{
+		ImportPaths:           []string{filepath.Dir(path)},
+		Accessor:              accessor,
+		LookupImport:          desc.LoadFileDescriptor,
+		IncludeSourceCodeInfo: true,
+	}
+	fds, err := p.ParseFiles(filepath.Base(path))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, md := range fds[0].GetMessageTypes() {
+		if resourceDescriptor(md.AsDescriptorProto()) == nil {
+			continue
+		}
+		imp := &importer{file: fds[0], resource: md}
+		var out bytes.Buffer
+		if err := imp.run(&out); err != nil {
+			t.Fatal(err)
+		}
+		return imp, out.String()
+	}
+
+	t.Fatalf("no resource found in %s", path)
+	return nil, ""
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	tests := []struct {
+		golden   string
+		children string
+	}{
+		{"default.proto", ""},
+		{"parent.proto", ""},
+		{"all.proto", "databases"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			imp, out := importFile(t, filepath.Join("testdata", tt.golden), nil)
+			if tt.children != "" {
+				// The child collections are not declared by the file.
+				imp.set("resource-children", tt.children)
+			} else if strings.Contains(out, "warning") {
+				t.Errorf("import reported warnings:\n%s", out)
+			}
+
+			fd, err := (&schemaBuilder{cfg: &imp.cfg}).Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got bytes.Buffer
+			if err := initPrinter(&imp.cfg).PrintProtoFile(fd, &got); err != nil {
+				t.Fatal(err)
+			}
+			golden(t, tt.golden, got.Bytes())
+		})
+	}
+}
+
+func TestImportBaseline(t *testing.T) {
+	// The files generated by the first releases have no resource pattern,
+	// which is inferred from the HTTP bindings.
+	tests := []struct {
+		previous string
+		parent   string
+	}{
+		{"v0.proto", ""},
+		{"v0_parent.proto", "organizations/{organization}"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.previous, func(t *testing.T) {
+			imp, out := importFile(t, filepath.Join("testdata", tt.previous), nil)
+			if strings.Contains(out, "warning") {
+				t.Errorf("import reported warnings:\n%s", out)
+			}
+			if imp.cfg.ParentPattern != tt.parent {
+				t.Errorf("import inferred the parent %q, want %q", imp.cfg.ParentPattern, tt.parent)
+			}
+		})
+	}
+}
+
+func TestImportStateTransitions(t *testing.T) {
+	const src = `syntax = "proto3";
+package acme.v1;
+import "google/api/annotations.proto";
+import "google/api/resource.proto";
+
+message Cluster {
+  option (google.api.resource) = { type: "acme.com/Cluster" };
+  string name = 1;
+  enum State { STATE_UNSPECIFIED = 0; RUNNING = 1; STOPPED = 2; STARTING = 3; STARTED = 4; ACTIVE = 5; }
+  State state = 9;
+}
+message GetClusterRequest { string name = 1; }
+message StopClusterRequest { string name = 1; }
+message StartClusterRequest { string name = 1; }
+message RestartClusterRequest { string name = 1; }
+message ResumeClusterRequest { string name = 1; }
+
+service ClusterService {
+  rpc GetCluster(GetClusterRequest) returns (Cluster) {
+    option (google.api.http) = { get: "/v1/{name=regions/*/clusters/*}" };
+  }
+  rpc StopCluster(StopClusterRequest) returns (Cluster) {
+    option (google.api.http) = { post: "/v1/{name=regions/*/clusters/*}:halt" body: "*" };
+  }
+  rpc StartCluster(StartClusterRequest) returns (Cluster) {
+    option (google.api.http) = { post: "/v1/{name=regions/*/clusters/*}:start" body: "*" };
+  }
+  rpc RestartCluster(RestartClusterRequest) returns (Cluster);
+  // Resume the Cluster resource, moving it to the ACTIVE state
+  rpc ResumeCluster(ResumeClusterRequest) returns (Cluster);
+}
+`
+	imp, out := importFile(t, "cluster.proto", protoparse.FileContentsFromMap(map[string]string{"cluster.proto": src}))
+
+	if got, want := fmt.Sprint(imp.cfg.StateTransitions), "[Resume=ACTIVE]"; got != want {
+		t.Errorf("import inferred the transitions %s, want %s", got, want)
+	}
+	if imp.cfg.ParentPattern != "regions/{region}" {
+		t.Errorf("import inferred the parent %q, want regions/{region}", imp.cfg.ParentPattern)
+	}
+	for _, warning := range []string{
+		"the target state of the StopCluster method cannot be inferred from its verb halt",
+		"the target state of the StartCluster method is ambiguous, one of STARTING, STARTED",
+		"the target state of the RestartCluster method cannot be inferred from its verb Restart",
+	} {
+		if !strings.Contains(out, warning) {
+			t.Errorf("import did not warn that %s:\n%s", warning, out)
+		}
+	}
+}
+
+func TestImportFields(t *testing.T) {
+	const src = `syntax = "proto3";
+package acme.v1;
+import "google/api/resource.proto";
+
+message Cluster {
+  option (google.api.resource) = { type: "acme.com/Cluster" pattern: "clusters/{cluster}" };
+  string name = 1;
+  int32 nodes = 20;
+  // The region hosting
+  // the cluster.
+  string region = 101;
+  Spec spec = 100;
+  message Spec { string zone = 3; }
+}
+`
+	imp, out := importFile(t, "cluster.proto", protoparse.FileContentsFromMap(map[string]string{"cluster.proto": src}))
+
+	if got, want := fmt.Sprint(imp.cfg.Fields), "[Spec.zone=3:string region=101:string#The region hosting the cluster. spec=100:Spec]"; got != want {
+		t.Errorf("import inferred the fields %s, want %s", got, want)
+	}
+	if warning := "the field nodes is numbered 20, below the 100 of the custom fields"; !strings.Contains(out, warning) {
+		t.Errorf("import did not warn that %s:\n%s", warning, out)
+	}
+}
+
+func TestImportLegacyFieldNumbers(t *testing.T) {
+	// A file generated by the first releases without display_name.
+	const src = `syntax = "proto3";
+package acme.v1;
+import "google/api/resource.proto";
+import "google/protobuf/timestamp.proto";
+
+message Cluster {
+  option (google.api.resource) = { type: "acme.com/Cluster" pattern: "clusters/{cluster}" };
+  string name = 1;
+  google.protobuf.Timestamp create_time = 2;
+  google.protobuf.Timestamp update_time = 3;
+  map<string, string> annotations = 4;
+}
+`
+	imp, out := importFile(t, "cluster.proto", protoparse.FileContentsFromMap(map[string]string{"cluster.proto": src}))
+
+	if !imp.cfg.LegacyFieldNumbers {
+		t.Errorf("import did not infer --legacy-field-numbers:\n%s", out)
+	}
+	if strings.Contains(out, "renumbered") {
+		t.Errorf("import reported renumbered fields:\n%s", out)
+	}
+}
+
+func TestMatchStates(t *testing.T) {
+	states := []string{"ACTIVE", "SUSPENDED", "STOPPED", "STARTING", "STARTED", "RUNNING", "ARCHIVED"}
+
+	tests := []struct {
+		verb string
+		want string
+	}{
+		{"suspend", "[SUSPENDED]"},
+		{"Suspend", "[SUSPENDED]"},
+		{"stop", "[STOPPED]"},
+		{"run", "[RUNNING]"},
+		{"archive", "[ARCHIVED]"},
+		{"activate", "[ACTIVE]"},
+		{"start", "[STARTING STARTED]"},
+		{"resume", "[]"},
+		{"sustain", "[]"},
+	}
+
+	for _, tt := range tests {
+		if got := fmt.Sprint(matchStates(tt.verb, states)); got != tt.want {
+			t.Errorf("matchStates(%q) = %s, want %s", tt.verb, got, tt.want)
+		}
+	}
+}
+
+func TestSingularOf(t *testing.T) {
+	for collection, want := range map[string]string{
+		"projects":        "project",
+		"policies":        "policy",
+		"instanceConfigs": "instance_config",
+		"data":            "data",
+	} {
+		if got := singularOf(collection); got != want {
+			t.Errorf("singularOf(%q) = %q, want %q", collection, got, want)
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"regexp"
 	"strings"
 
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/sqlstore"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 	"github.com/stoewer/go-strcase"
 )
 
@@ -30,6 +32,33 @@ type Config struct {
 	WithTimestamps bool
 	// Whether to generate the annotations field
 	WithAnnotations bool
+	// Whether to generate the uid field
+	WithUID bool
+	// Whether to generate the etag field, on the resource and the Delete request
+	WithEtag bool
+	// Whether to generate the reconciling field
+	WithReconciling bool
+	// Whether to generate the labels field
+	WithLabels bool
+	// Whether to generate the expire_time/ttl expiration oneof
+	WithExpiration bool
+	// Custom fields of the resource or of its nested messages, as
+	// `[Message.]name=NUMBER:type[:BEHAVIOR+BEHAVIOR...][#description]`
+	Fields []string
+	// Enums nested in the resource, as `Name=VALUE,VALUE...`
+	Enums []string
+	// Reserved field numbers, ranges and names of the resource, e.g. `15`,
+	// `20-25` or `old_field`
+	Reserved []string
+	// Whether to number the standard fields of the first releases by position,
+	// as they did, rather than with their fixed numbers
+	LegacyFieldNumbers bool
+	// Collection identifiers of the child resources, if any
+	ChildCollections []string
+	// States of the lifecycle of the resource, generating the State enum and field
+	States []string
+	// State-transition methods, as `Verb=STATE` pairs, e.g. `Suspend=SUSPENDED`
+	StateTransitions []string
 
 	// Flags controlling the generated methods
 
@@ -37,12 +66,38 @@ type Config struct {
 	WithListOrderBy bool
 	// Whether to generate the filter field for list method
 	WithListFilter bool
+	// Whether the list method supports reading across parents with `-` wildcards
+	WithListWildcardParent bool
+	// Whether to generate the skip field for list method
+	WithListSkip bool
+	// Whether to generate the total_size field for list method
+	WithListTotalSize bool
+	// Whether to generate the unreachable field for list method
+	WithListUnreachable bool
 	// Whether to generate the update_mask field for update method
 	WithUpdateFieldMask bool
 	// Whether to generate the allow_missing field for update method
 	WithUpdateAllowMissing bool
 	// Whether to generate the allow_missing field for delete method
 	WithDeleteAllowMissing bool
+	// Whether to generate the request_id field for create, update and delete methods
+	WithRequestID bool
+	// Whether to generate the validate_only field for create, update, delete and custom methods
+	WithValidateOnly bool
+	// Whether to generate the read_mask field for get and list methods
+	WithReadMask bool
+	// Whether to generate the <Resource>View enum and the view field for get and list methods
+	WithView bool
+
+	// Whether to generate the Search custom method
+	WithSearch bool
+	// Whether to generate the server-streaming Watch methods
+	WithWatch bool
+	// Whether to generate the GetIamPolicy, SetIamPolicy and TestIamPermissions methods
+	WithIAM bool
+	// Whether to generate the revision fields and the Commit, ListRevisions, Rollback and
+	// DeleteRevision methods
+	WithRevisions bool
 
 	// Flags controlling the generated options
 
@@ -50,12 +105,66 @@ type Config struct {
 	WithHTTPOptions bool
 
 	Compact bool
+
+	// Flags controlling the generated output
+
+	// Kind of output to generate, see outputKinds
+	Output string
+	// Name of the Go package for Go outputs, derived from Package if empty
+	GoPackage string
+	// SQL dialect of the sql output
+	SQLDialect string
 }
 
 func (c *Config) HasParent() bool {
 	return c.ParentPattern != ""
 }
 
+// HasChildren returns whether the resource is declared as the parent of other
+// resources, in which case deleting it cascades only when forced.
+func (c *Config) HasChildren() bool {
+	return len(c.ChildCollections) > 0
+}
+
+// StateTransition is a custom method moving the resource to a state.
+type StateTransition struct {
+	// Verb of the method, e.g. `Suspend` for the `SuspendProject` method.
+	Verb string
+	// State of the resource after the transition, e.g. `SUSPENDED`.
+	State string
+}
+
+var (
+	stateName = regexp.MustCompile(`^[A-Z][A-Z0-9_]*$`)
+	verbName  = regexp.MustCompile(`^[A-Z][a-zA-Z0-9]*$`)
+)
+
+// ParseStateTransitions validates the states and parses the state-transition
+// methods of the resource.
+func (c *Config) ParseStateTransitions() ([]StateTransition, error) {
+	states := map[string]bool{}
+	for _, state := range c.States {
+		if !stateName.MatchString(state) || state == "STATE_UNSPECIFIED" {
+			return nil, fmt.Errorf("invalid state %q, must be UPPER_SNAKE_CASE", state)
+		}
+		states[state] = true
+	}
+
+	var transitions []StateTransition
+	for _, t := range c.StateTransitions {
+		verb, state, ok := strings.Cut(t, "=")
+		if !ok || !verbName.MatchString(verb) {
+			return nil, fmt.Errorf("invalid state transition %q, must be a Verb=STATE pair", t)
+		}
+		if !states[state] {
+			return nil, fmt.Errorf("state transition %q targets an unknown state, must be one of --state", t)
+		}
+		transitions = append(transitions, StateTransition{Verb: verb, State: state})
+	}
+
+	return transitions, nil
+}
+
 func (c *Config) ResourceCollectionIdentifier() string {
 	return strcase.LowerCamelCase(c.PluralResource)
 }
@@ -84,6 +193,12 @@ func (c *Config) ResourceTypeName() string {
 	return fmt.Sprintf("%s/%s", c.Service, c.Resource)
 }
 
+// ResourceViewName returns the name of the resource view enum, e.g.
+// `ProjectView`.
+func (c *Config) ResourceViewName() string {
+	return c.Resource + "View"
+}
+
 func (c *Config) ResourceSnakeCase() string {
 	return strcase.SnakeCase(c.Resource)
 }
@@ -96,6 +211,108 @@ func (c *Config) ParentNameUrlRef() string {
 	return replaceCurly.ReplaceAllString(c.ParentPattern, "*")
 }
 
+var versionComponent = regexp.MustCompile(`^v\d+`)
+
+// GoPackageName returns the name of the Go package for Go outputs. Unless
+// overridden, it is derived from the protobuf package the same way buf does,
+// e.g. `acme.v1` becomes `acmev1`.
+func (c *Config) GoPackageName() string {
+	if c.GoPackage != "" {
+		return c.GoPackage
+	}
+
+	parts := strings.Split(c.Package, ".")
+	last := parts[len(parts)-1]
+	if len(parts) > 1 && versionComponent.MatchString(last) {
+		return parts[len(parts)-2] + last
+	}
+
+	return last
+}
+
+// complete sets the resource of the config, defaults its plural form and
+// validates the flags.
+func (c *Config) complete(resource string) error {
+	c.Resource = resource
+
+	if c.PluralResource == "" {
+		c.PluralResource = c.Resource + "s"
+	}
+
+	if c.WithReadMask && c.WithView {
+		return fmt.Errorf("--with-read-mask and --with-view are mutually exclusive")
+	}
+
+	if _, err := c.ParseStateTransitions(); err != nil {
+		return err
+	}
+
+	if _, err := c.ParseFields(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+const (
+	outputProto        = "proto"
+	outputGoName       = "go-name"
+	outputSQL          = "sql"
+	outputGoRepository = "go-repository"
+)
+
+var outputKinds = []string{outputProto, outputGoName, outputSQL, outputGoRepository}
+
+// registerFlags registers the flags controlling the generated resource and
+// methods on fs, as shared by the commands generating it.
+func (c *Config) registerFlags(fs *pflag.FlagSet) {
+	fs.StringVar(&c.PluralResource, "resource-plural", "", "Plural form of the resource name")
+	fs.StringVar(&c.ParentPattern, "resource-parent", "", "Pattern of the parent resource, if any")
+	fs.BoolVar(&c.IDRequired, "resource-id-required", false, "Whether the resource id is required in the Create/Update methods")
+	fs.BoolVar(&c.WithDisplayName, "resource-with-display-name", true, "Whether to generate the display_name field for resource")
+	fs.BoolVar(&c.WithTimestamps, "resource-with-timestamps", true, "Whether to generate fields for resource name and create/update timestamps")
+	fs.BoolVar(&c.WithAnnotations, "resource-with-annotations", true, "Whether to generate the annotations field for the resource")
+	fs.BoolVar(&c.WithUID, "resource-with-uid", false, "Whether to generate the uid field for the resource")
+	fs.BoolVar(&c.WithEtag, "resource-with-etag", false, "Whether to generate the etag field for the resource, the delete and the state-transition methods")
+	fs.BoolVar(&c.WithReconciling, "resource-with-reconciling", false, "Whether to generate the reconciling field for the resource")
+	fs.BoolVar(&c.WithLabels, "resource-with-labels", false, "Whether to generate the labels field for the resource")
+	fs.BoolVar(&c.WithExpiration, "resource-with-expiration", false, "Whether to generate the expire_time/ttl expiration oneof for the resource")
+	fs.StringArrayVar(&c.Fields, "field", nil, "Custom field of the resource as [Message.]name=NUMBER:type[:BEHAVIOR+BEHAVIOR...][#description], e.g. region=100:string:REQUIRED+IMMUTABLE, Spec.replicas=1:int32 or zone=101:string#The hosting zone (repeatable)")
+	fs.StringSliceVar(&c.Reserved, "reserved", nil, "Comma-separated reserved field numbers, N-M ranges or names of the resource, e.g. 101,110-119,old_field")
+	fs.BoolVar(&c.LegacyFieldNumbers, "legacy-field-numbers", false, "Number the standard fields of the first releases by position, as they did, to keep the numbers of the files they generated with some of these fields disabled")
+	fs.StringArrayVar(&c.Enums, "enum", nil, "Enum nested in the resource as Name=VALUE,VALUE..., e.g. Tier=BASIC,PREMIUM (repeatable)")
+	fs.StringSliceVar(&c.States, "state", nil, "Comma-separated states of the lifecycle of the resource, e.g. CREATING,ACTIVE,SUSPENDED,DELETING")
+	fs.StringSliceVar(&c.StateTransitions, "state-transitions", nil, "Comma-separated state-transition methods as Verb=STATE pairs, e.g. Suspend=SUSPENDED,Resume=ACTIVE")
+	fs.StringSliceVar(&c.ChildCollections, "resource-children", nil, "Comma-separated collection identifiers of the child resources, if any")
+
+	fs.StringVar(&c.Package, "package", "", "Package name for the generated protobuf file")
+	fs.StringVar(&c.Service, "service", "", "Service name for the generated protobuf file")
+
+	fs.StringVar(&c.Syntax, "syntax", "proto3", "Syntax for the generated protobuf file")
+
+	fs.StringVar(&c.Methods, "methods", "crudl", "Comma-separated list of methods to generate")
+
+	fs.BoolVar(&c.WithHTTPOptions, "with-http-options", true, "Generate HTTP-specific options")
+	fs.BoolVar(&c.WithListOrderBy, "with-list-order-by", true, "Generate the order_by field for list method")
+	fs.BoolVar(&c.WithListFilter, "with-list-filter", true, "Generate the filter field for list method")
+	fs.BoolVar(&c.WithListWildcardParent, "with-list-wildcard-parent", false, "Document reads across parents with - wildcards for list method")
+	fs.BoolVar(&c.WithListSkip, "with-list-skip", false, "Generate the skip field for list method")
+	fs.BoolVar(&c.WithListTotalSize, "with-list-total-size", false, "Generate the total_size field for list method")
+	fs.BoolVar(&c.WithListUnreachable, "with-list-unreachable", false, "Generate the unreachable field for list method")
+	fs.BoolVar(&c.WithUpdateFieldMask, "with-update-field-mask", true, "Generate the update_mask field for update method")
+	fs.BoolVar(&c.WithUpdateAllowMissing, "with-update-allow-missing", true, "Generate the allow_missing field for update method")
+	fs.BoolVar(&c.WithDeleteAllowMissing, "with-delete-allow-missing", true, "Generate the allow_missing field for delete method")
+	fs.BoolVar(&c.WithRequestID, "with-request-id", false, "Generate the request_id field for create, update and delete methods")
+	fs.BoolVar(&c.WithValidateOnly, "with-validate-only", false, "Generate the validate_only field for create, update, delete and custom methods")
+	fs.BoolVar(&c.WithReadMask, "with-read-mask", false, "Generate the read_mask field for get and list methods")
+	fs.BoolVar(&c.WithView, "with-view", false, "Generate the resource view enum and the view field for get and list methods")
+
+	fs.BoolVar(&c.WithSearch, "with-search", false, "Generate the search custom method with a free-text query on the collection")
+	fs.BoolVar(&c.WithWatch, "with-watch", false, "Generate the server-streaming watch methods of the resource and of its collection")
+	fs.BoolVar(&c.WithIAM, "with-iam", false, "Generate the GetIamPolicy, SetIamPolicy and TestIamPermissions methods")
+	fs.BoolVar(&c.WithRevisions, "with-revisions", false, "Generate the revision fields and the Commit, ListRevisions, Rollback and DeleteRevision methods")
+}
+
 func main() {
 	var cfg Config
 
@@ -104,51 +321,49 @@ func main() {
 		Short: "Scaffold protobuf IDL file for AIP resource",
 		Args:  cobra.ExactArgs(1),
 		RunE: func(cmd *cobra.Command, args []string) error {
-			cfg.Resource = args[0]
-
-			if cfg.PluralResource == "" {
-				cfg.PluralResource = cfg.Resource + "s"
+			if err := cfg.complete(args[0]); err != nil {
+				return err
 			}
 
-			s := &schemaBuilder{cfg: &cfg}
+			switch cfg.Output {
+			case outputProto, outputSQL:
+				s := &schemaBuilder{cfg: &cfg}
 
-			desc, err := s.Build()
-			if err != nil {
-				return fmt.Errorf("failed to generate file descriptor: %v", err)
-			}
+				desc, err := s.Build()
+				if err != nil {
+					return fmt.Errorf("failed to generate file descriptor: %v", err)
+				}
 
-			printer := initPrinter(&cfg)
+				if cfg.Output == outputSQL {
+					return generateSQL(&cfg, desc, os.Stdout)
+				}
 
-			return printer.PrintProtoFile(desc, os.Stdout)
+				printer := initPrinter(&cfg)
 
+				return printer.PrintProtoFile(desc, os.Stdout)
+			case outputGoName:
+				return generateResourceName(&cfg, os.Stdout)
+			case outputGoRepository:
+				return generateRepository(&cfg, os.Stdout)
+			default:
+				return fmt.Errorf("unknown output %q, must be one of %s", cfg.Output, strings.Join(outputKinds, ", "))
+			}
 		},
 	}
 
 	// Resource flags
-	cmd.Flags().StringVar(&cfg.PluralResource, "resource-plural", "", "Plural form of the resource name")
-	cmd.Flags().StringVar(&cfg.ParentPattern, "resource-parent", "", "Pattern of the parent resource, if any")
-	cmd.Flags().BoolVar(&cfg.IDRequired, "resource-id-required", false, "Whether the resource id is required in the Create/Update methods")
-	cmd.Flags().BoolVar(&cfg.WithDisplayName, "resource-with-display-name", true, "Whether to generate the display_name field for resource")
-	cmd.Flags().BoolVar(&cfg.WithTimestamps, "resource-with-timestamps", true, "Whether to generate fields for resource name and create/update timestamps")
-	cmd.Flags().BoolVar(&cfg.WithAnnotations, "resource-with-annotations", true, "Whether to generate the annotations field for the resource")
-
-	cmd.Flags().StringVar(&cfg.Package, "package", "", "Package name for the generated protobuf file")
+	cfg.registerFlags(cmd.Flags())
 	cmd.MarkFlagRequired("package")
-	cmd.Flags().StringVar(&cfg.Service, "service", "", "Service name for the generated protobuf file")
 	cmd.MarkFlagRequired("service")
 
-	cmd.Flags().StringVar(&cfg.Syntax, "syntax", "proto3", "Syntax for the generated protobuf file")
-
-	cmd.Flags().StringVar(&cfg.Methods, "methods", "crudl", "Comma-separated list of methods to generate")
+	cmd.Flags().BoolVar(&cfg.Compact, "compact", false, "Generate compact proto file")
 
-	cmd.Flags().BoolVar(&cfg.WithHTTPOptions, "with-http-options", true, "Generate HTTP-specific options")
-	cmd.Flags().BoolVar(&cfg.WithListOrderBy, "with-list-order-by", true, "Generate the order_by field for list method")
-	cmd.Flags().BoolVar(&cfg.WithListFilter, "with-list-filter", true, "Generate the filter field for list method")
-	cmd.Flags().BoolVar(&cfg.WithUpdateFieldMask, "with-update-field-mask", true, "Generate the update_mask field for update method")
-	cmd.Flags().BoolVar(&cfg.WithUpdateAllowMissing, "with-update-allow-missing", true, "Generate the allow_missing field for update method")
-	cmd.Flags().BoolVar(&cfg.WithDeleteAllowMissing, "with-delete-allow-missing", true, "Generate the allow_missing field for delete method")
+	cmd.Flags().StringVar(&cfg.Output, "output", outputProto, "Kind of output to generate, one of "+strings.Join(outputKinds, ", "))
+	cmd.Flags().StringVar(&cfg.GoPackage, "go-package", "", "Go package name for Go outputs, derived from --package if empty")
+	cmd.Flags().StringVar(&cfg.SQLDialect, "sql-dialect", sqlstore.Postgres.Name(), "SQL dialect of the sql output, one of postgres, sqlite")
 
-	cmd.Flags().BoolVar(&cfg.Compact, "compact", false, "Generate compact proto file")
+	cmd.AddCommand(newBreakingCommand(&cfg))
+	cmd.AddCommand(newImportCommand())
 
 	if err := cmd.Execute(); err != nil {
 		fmt.Fprintln(os.Stderr, err)
@@ -0,0 +1,138 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/jhump/protoreflect/desc"
+	"github.com/spf13/pflag"
+)
+
+var update = flag.Bool("update", false, "update the golden files of testdata")
+
+// newConfig returns the config of the resource set by the flags in args, as
+// the root command would.
+func newConfig(t *testing.T, resource string, args ...string) *Config {
+	t.Helper()
+
+	var cfg Config
+	fs := pflag.NewFlagSet(resource, pflag.ContinueOnError)
+	cfg.registerFlags(fs)
+	if err := fs.Parse(append([]string{"--package", "acme.v1", "--service", "api.acme.com"}, args...)); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.complete(resource); err != nil {
+		t.Fatal(err)
+	}
+
+	return &cfg
+}
+
+// build returns the file generated for the resource with the flags in args.
+func build(t *testing.T, resource string, args ...string) *desc.FileDescriptor {
+	t.Helper()
+
+	fd, err := (&schemaBuilder{cfg: newConfig(t, resource, args...)}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fd
+}
+
+// generate returns the proto file generated for the resource with the flags
+// in args.
+func generate(t *testing.T, resource string, args ...string) []byte {
+	t.Helper()
+
+	cfg := newConfig(t, resource, args...)
+	fd, err := (&schemaBuilder{cfg: cfg}).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := initPrinter(cfg).PrintProtoFile(fd, &buf); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// golden compares got with the golden file name of testdata, rewriting it
+// with -update.
+func golden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s differs from the generated file, run the tests with -update to see the difference in git\n%s", path, got)
+	}
+}
+
+func TestParseStateTransitions(t *testing.T) {
+	tests := []struct {
+		states, transitions []string
+		want                string
+		wantErr             bool
+	}{
+		{nil, nil, "[]", false},
+		{[]string{"ACTIVE", "SUSPENDED"}, []string{"Suspend=SUSPENDED", "Resume=ACTIVE"}, "[{Suspend SUSPENDED} {Resume ACTIVE}]", false},
+		{[]string{"ACTIVE"}, []string{"ForceActivate=ACTIVE"}, "[{ForceActivate ACTIVE}]", false},
+		{[]string{"active"}, nil, "", true},
+		{[]string{"STATE_UNSPECIFIED"}, nil, "", true},
+		{[]string{"ACTIVE"}, []string{"Suspend=SUSPENDED"}, "", true},
+		{[]string{"ACTIVE"}, []string{"suspend=ACTIVE"}, "", true},
+		{[]string{"ACTIVE"}, []string{"Activate"}, "", true},
+	}
+
+	for _, tt := range tests {
+		c := &Config{States: tt.states, StateTransitions: tt.transitions}
+		got, err := c.ParseStateTransitions()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseStateTransitions(%v, %v) = %v, want error %t", tt.states, tt.transitions, err, tt.wantErr)
+			continue
+		}
+		if err == nil && fmt.Sprint(got) != tt.want {
+			t.Errorf("ParseStateTransitions(%v, %v) = %v, want %s", tt.states, tt.transitions, got, tt.want)
+		}
+	}
+}
+
+func TestBuildInvalidConfig(t *testing.T) {
+	// The config is changed after complete validated it, as by a caller
+	// building a file without it.
+	tests := []struct {
+		name   string
+		modify func(*Config)
+	}{
+		{"invalid field", func(c *Config) { c.Fields = []string{"region"} }},
+		{"invalid reserved", func(c *Config) { c.Reserved = []string{"0"} }},
+		{"invalid state transition", func(c *Config) { c.StateTransitions = []string{"Suspend=SUSPENDED"} }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := newConfig(t, "Project")
+			tt.modify(cfg)
+			if _, err := (&schemaBuilder{cfg: cfg}).Build(); err == nil {
+				t.Errorf("Build() succeeded")
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/jhump/protoreflect/desc/builder"
+)
+
+const (
+	// customFieldNumberStart is the first number of the custom fields of the
+	// resource, the lower numbers being reserved to its standard fields.
+	customFieldNumberStart = 100
+	// maxFieldNumber is the largest valid field number.
+	maxFieldNumber = 536870911
+	// firstImplementationReservedNumber and lastImplementationReservedNumber
+	// bound the field numbers reserved to the protobuf implementation.
+	firstImplementationReservedNumber = 19000
+	lastImplementationReservedNumber  = 19999
+)
+
+// resourceFieldNumbers are the fixed numbers of the standard fields of the
+// resource. A field keeps its number whether the other fields are generated or
+// not, so that toggling an option never renumbers the fields on the wire. The
+// fields generated by default keep the numbers they were first released with.
+var resourceFieldNumbers = map[string]int32{
+	"name":                 1,
+	"display_name":         2,
+	"create_time":          3,
+	"update_time":          4,
+	"annotations":          5,
+	"uid":                  6,
+	"etag":                 7,
+	"reconciling":          8,
+	"state":                9,
+	"labels":               10,
+	"expire_time":          11,
+	"ttl":                  12,
+	"revision_id":          13,
+	"revision_create_time": 14,
+}
+
+// messageFieldNumbers returns the fixed numbers of the fields of the request,
+// response and event messages, by message name, like resourceFieldNumbers
+// does for the resource. The fields of the standard methods generated by
+// default keep the numbers they were first released with, which follow the
+// parent when there is one, and the other fields are numbered after them.
+func (c *Config) messageFieldNumbers() (map[string]map[string]int32, error) {
+	r, plural := c.Resource, c.PluralResource
+	resource, resources := c.ResourceSnakeCase(), c.PluralResourceSnakeCase()
+
+	// The shift of the fields following the parent.
+	var p int32
+	if c.HasParent() {
+		p = 1
+	}
+
+	page := map[string]int32{resources: 1, "next_page_token": 2}
+	numbers := map[string]map[string]int32{
+		"Get" + r + "Request":            {"name": 1, "read_mask": 2, "view": 3},
+		"List" + r + "Request":           {"parent": 1, "page_size": 1 + p, "page_token": 2 + p, "filter": 3 + p, "order_by": 4 + p, "skip": 6, "read_mask": 7, "view": 8},
+		"List" + r + "Response":          {resources: 1, "next_page_token": 2, "total_size": 3, "unreachable": 4},
+		"Create" + r + "Request":         {"parent": 1, resource + "_id": 1 + p, resource: 2 + p, "request_id": 4, "validate_only": 5},
+		"Update" + r + "Request":         {resource: 1, "update_mask": 2, "allow_missing": 3, "request_id": 4, "validate_only": 5},
+		"Delete" + r + "Request":         {"name": 1, "allow_missing": 2, "etag": 3, "force": 4, "request_id": 5, "validate_only": 6},
+		"Search" + plural + "Request":    {"parent": 1, "query": 2, "page_size": 3, "page_token": 4, "order_by": 5},
+		"Search" + plural + "Response":   page,
+		r + "Event":                      {"type": 1, resource: 2, "resume_token": 3},
+		"Watch" + r + "Request":          {"name": 1, "resume_token": 2},
+		"Watch" + plural + "Request":     {"parent": 1, "resume_token": 2},
+		"Commit" + r + "Request":         {"name": 1, "validate_only": 2},
+		"List" + r + "RevisionsRequest":  {"name": 1, "page_size": 2, "page_token": 3},
+		"List" + r + "RevisionsResponse": page,
+		"Rollback" + r + "Request":       {"name": 1, "revision_id": 2, "validate_only": 3},
+		"Delete" + r + "RevisionRequest": {"name": 1, "validate_only": 2},
+	}
+
+	transitions, err := c.ParseStateTransitions()
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range transitions {
+		numbers[t.Verb+r+"Request"] = map[string]int32{"name": 1, "validate_only": 2, "etag": 3}
+	}
+
+	return numbers, nil
+}
+
+// firstReleaseFields returns the standard fields generated by the first
+// releases, by message name, in the order they numbered them: by position,
+// leaving no gap for the fields disabled by an option, e.g. `create_time = 2`
+// without `display_name`.
+func (c *Config) firstReleaseFields() map[string][]string {
+	r, resource, resources := c.Resource, c.ResourceSnakeCase(), c.PluralResourceSnakeCase()
+
+	return map[string][]string{
+		r:                        {"name", "display_name", "create_time", "update_time", "annotations"},
+		"Get" + r + "Request":    {"name"},
+		"List" + r + "Request":   {"parent", "page_size", "page_token", "filter", "order_by"},
+		"List" + r + "Response":  {resources, "next_page_token"},
+		"Create" + r + "Request": {"parent", resource + "_id", resource},
+		"Update" + r + "Request": {resource, "update_mask", "allow_missing"},
+		"Delete" + r + "Request": {"name", "allow_missing"},
+	}
+}
+
+// legacyFieldNumbers returns the numbers the first releases gave to the
+// fields of mb among fields, by position.
+func legacyFieldNumbers(mb *builder.MessageBuilder, fields []string) map[string]int32 {
+	numbers := map[string]int32{}
+	for _, name := range fields {
+		if mb.GetField(name) != nil {
+			numbers[name] = int32(len(numbers) + 1)
+		}
+	}
+	return numbers
+}
+
+// numberFields sets the fixed numbers of the standard fields of the messages of
+// the file, or their numbers by position for the fields of the first releases
+// with --legacy-field-numbers. The custom fields are numbered when they are
+// added.
+func (s *schemaBuilder) numberFields() error {
+	messageNumbers, err := s.cfg.messageFieldNumbers()
+	if err != nil {
+		return err
+	}
+	firstRelease := s.cfg.firstReleaseFields()
+
+	for _, child := range s.file.GetChildren() {
+		mb, ok := child.(*builder.MessageBuilder)
+		if !ok {
+			continue
+		}
+
+		numbers, ok := messageNumbers[mb.GetName()]
+		if mb == s.resource {
+			numbers, ok = resourceFieldNumbers, true
+		}
+		if !ok {
+			return fmt.Errorf("no field numbers for message %s", mb.GetName())
+		}
+		var legacy map[string]int32
+		if s.cfg.LegacyFieldNumbers {
+			legacy = legacyFieldNumbers(mb, firstRelease[mb.GetName()])
+		}
+
+		for _, f := range messageFields(mb) {
+			if f.GetNumber() != 0 {
+				continue
+			}
+			n, ok := legacy[f.GetName()]
+			if !ok {
+				n, ok = numbers[f.GetName()]
+			}
+			if !ok {
+				return fmt.Errorf("no field number for %s.%s", mb.GetName(), f.GetName())
+			}
+			f.SetNumber(n)
+		}
+	}
+
+	return nil
+}
+
+// messageFields returns the fields of mb, including those of its oneofs.
+func messageFields(mb *builder.MessageBuilder) []*builder.FieldBuilder {
+	var fields []*builder.FieldBuilder
+	for _, child := range mb.GetChildren() {
+		switch child := child.(type) {
+		case *builder.FieldBuilder:
+			fields = append(fields, child)
+		case *builder.OneOfBuilder:
+			for _, choice := range child.GetChildren() {
+				fields = append(fields, choice.(*builder.FieldBuilder))
+			}
+		}
+	}
+	return fields
+}
+
+// reservedRange is an inclusive range of reserved field numbers.
+type reservedRange struct {
+	Start, End int32
+}
+
+func (r reservedRange) contains(n int32) bool {
+	return r.Start <= n && n <= r.End
+}
+
+// ParseReserved parses the reserved field numbers, ranges and names of the
+// resource, e.g. `15`, `20-25` or `old_field`.
+func (c *Config) ParseReserved() ([]reservedRange, []string, error) {
+	var (
+		ranges []reservedRange
+		names  []string
+	)
+
+	for _, r := range c.Reserved {
+		if fieldName.MatchString(r) {
+			names = append(names, r)
+			continue
+		}
+
+		start, end, isRange := strings.Cut(r, "-")
+		if !isRange {
+			end = start
+		}
+		first, err1 := strconv.ParseInt(start, 10, 32)
+		last, err2 := strconv.ParseInt(end, 10, 32)
+		if err1 != nil || err2 != nil || first < 1 || last < first || last > maxFieldNumber {
+			return nil, nil, fmt.Errorf("invalid reserved %q, must be a field number, a N-M range or a field name", r)
+		}
+		ranges = append(ranges, reservedRange{Start: int32(first), End: int32(last)})
+	}
+
+	return ranges, names, nil
+}
+
+// validFieldNumber reports whether n can be used as a field number.
+func validFieldNumber(n int32) bool {
+	return n >= 1 && n <= maxFieldNumber &&
+		(n < firstImplementationReservedNumber || n > lastImplementationReservedNumber)
+}
+
+// addReserved adds the reserved field numbers and names to the resource
+// message b.
+func addReserved(c *Config, b *builder.MessageBuilder) error {
+	ranges, names, err := c.ParseReserved()
+	if err != nil {
+		return err
+	}
+
+	for _, r := range ranges {
+		b.AddReservedRange(r.Start, r.End)
+	}
+	for _, name := range names {
+		b.AddReservedName(name)
+	}
+
+	return nil
+}
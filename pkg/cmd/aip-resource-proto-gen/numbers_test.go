@@ -0,0 +1,104 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestGoldenProto(t *testing.T) {
+	tests := []struct {
+		golden string
+		args   []string
+	}{
+		{"default.proto", nil},
+		{"parent.proto", []string{"--resource-parent", "organizations/{organization}"}},
+		{"all.proto", []string{
+			"--resource-parent", "organizations/{organization}",
+			"--resource-children", "databases",
+			"--resource-with-uid", "--resource-with-etag", "--resource-with-reconciling",
+			"--resource-with-labels", "--resource-with-expiration",
+			"--state", "ACTIVE,SUSPENDED", "--state-transitions", "Suspend=SUSPENDED",
+			"--enum", "Tier=BASIC,PREMIUM",
+			"--field", "region=100:string:REQUIRED+IMMUTABLE#The region hosting the project.",
+			"--field", "spec=101:Spec",
+			"--field", "Spec.tier=1:Tier",
+			"--reserved", "110-119,old_field",
+			"--with-request-id", "--with-validate-only", "--with-view",
+			"--with-list-wildcard-parent", "--with-list-skip", "--with-list-total-size", "--with-list-unreachable",
+			"--with-search", "--with-watch", "--with-iam", "--with-revisions",
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			golden(t, tt.golden, generate(t, "Project", tt.args...))
+		})
+	}
+}
+
+func TestFieldNumbersStable(t *testing.T) {
+	// The fields generated by default keep the numbers of the first releases.
+	want := map[string]map[string]int32{
+		"Project":              {"name": 1, "display_name": 2, "create_time": 3, "update_time": 4, "annotations": 5},
+		"GetProjectRequest":    {"name": 1},
+		"ListProjectRequest":   {"parent": 1, "page_size": 2, "page_token": 3, "filter": 4, "order_by": 5},
+		"ListProjectResponse":  {"projects": 1, "next_page_token": 2},
+		"CreateProjectRequest": {"parent": 1, "project_id": 2, "project": 3},
+		"UpdateProjectRequest": {"project": 1, "update_mask": 2, "allow_missing": 3},
+		"DeleteProjectRequest": {"name": 1, "allow_missing": 2},
+	}
+
+	for _, args := range [][]string{
+		{"--resource-parent", "organizations/{organization}"},
+		{"--resource-parent", "organizations/{organization}", "--resource-with-uid", "--resource-with-etag",
+			"--with-request-id", "--with-validate-only", "--with-list-skip", "--with-read-mask", "--with-revisions"},
+	} {
+		fd := build(t, "Project", args...)
+		for message, fields := range want {
+			md := fd.FindMessage("acme.v1." + message)
+			if md == nil {
+				t.Fatalf("%v: message %s not found", args, message)
+			}
+			for name, n := range fields {
+				if f := md.FindFieldByName(name); f == nil || f.GetNumber() != n {
+					t.Errorf("%v: %s.%s is not numbered %d", args, message, name, n)
+				}
+			}
+		}
+	}
+}
+
+func TestLegacyFieldNumbers(t *testing.T) {
+	args := []string{"--resource-with-display-name=false", "--with-list-filter=false", "--resource-with-uid"}
+
+	tests := []struct {
+		legacy bool
+		want   map[string]map[string]int32
+	}{
+		{false, map[string]map[string]int32{
+			"Project":            {"name": 1, "create_time": 3, "update_time": 4, "annotations": 5, "uid": 6},
+			"ListProjectRequest": {"page_size": 1, "page_token": 2, "order_by": 4},
+		}},
+		// The first releases numbered the fields by position, and the fields
+		// added since keep their fixed numbers.
+		{true, map[string]map[string]int32{
+			"Project":            {"name": 1, "create_time": 2, "update_time": 3, "annotations": 4, "uid": 6},
+			"ListProjectRequest": {"page_size": 1, "page_token": 2, "order_by": 3},
+		}},
+	}
+
+	for _, tt := range tests {
+		args := args
+		if tt.legacy {
+			args = append(args, "--legacy-field-numbers")
+		}
+		fd := build(t, "Project", args...)
+		for message, fields := range tt.want {
+			md := fd.FindMessage("acme.v1." + message)
+			for name, n := range fields {
+				if f := md.FindFieldByName(name); f == nil || f.GetNumber() != n {
+					t.Errorf("%v: %s.%s is not numbered %d", args, message, name, n)
+				}
+			}
+		}
+	}
+}
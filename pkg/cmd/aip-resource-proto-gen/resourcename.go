@@ -0,0 +1,259 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/stoewer/go-strcase"
+)
+
+// patternSegment is a single component of a resource name pattern, either a
+// collection literal (e.g. `projects`) or a variable (e.g. `{project}`).
+type patternSegment struct {
+	Literal  string
+	Variable string
+}
+
+func (p patternSegment) IsVariable() bool {
+	return p.Variable != ""
+}
+
+// FieldName is the name of the Go struct field holding the variable.
+func (p patternSegment) FieldName() string {
+	return strcase.UpperCamelCase(p.Variable)
+}
+
+var (
+	patternLiteral  = regexp.MustCompile(`^[a-z][a-zA-Z0-9]*$`)
+	patternVariable = regexp.MustCompile(`^\{([a-z][a-z0-9_]*)\}$`)
+)
+
+func parsePattern(pattern string) ([]patternSegment, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty resource name pattern")
+	}
+
+	var segments []patternSegment
+	seen := map[string]bool{}
+	for _, part := range strings.Split(pattern, "/") {
+		if m := patternVariable.FindStringSubmatch(part); m != nil {
+			if seen[m[1]] {
+				return nil, fmt.Errorf("pattern %q: duplicate variable %q", pattern, m[1])
+			}
+			seen[m[1]] = true
+			segments = append(segments, patternSegment{Variable: m[1]})
+			continue
+		}
+
+		if !patternLiteral.MatchString(part) {
+			return nil, fmt.Errorf("pattern %q: invalid segment %q", pattern, part)
+		}
+		segments = append(segments, patternSegment{Literal: part})
+	}
+
+	return segments, nil
+}
+
+type resourceNameData struct {
+	Package       string
+	Type          string
+	TypeName      string
+	Pattern       string
+	Segments      []patternSegment
+	ParentPattern string
+	Parent        []patternSegment
+}
+
+func (d resourceNameData) Variables() []patternSegment {
+	return variables(d.Segments)
+}
+
+// ParentVariables are the variables of the parents of the resource, which
+// may be the wildcard.
+func (d resourceNameData) ParentVariables() []patternSegment {
+	return variables(d.Parent)
+}
+
+// IDVariable is the variable of the ID of the resource.
+func (d resourceNameData) IDVariable() patternSegment {
+	return d.Segments[len(d.Segments)-1]
+}
+
+func variables(segments []patternSegment) []patternSegment {
+	var vars []patternSegment
+	for _, s := range segments {
+		if s.IsVariable() {
+			vars = append(vars, s)
+		}
+	}
+	return vars
+}
+
+func generateResourceName(c *Config, w io.Writer) error {
+	segments, err := parsePattern(c.ResourceNamePattern())
+	if err != nil {
+		return err
+	}
+
+	data := resourceNameData{
+		Package:       c.GoPackageName(),
+		Type:          c.Resource + "Name",
+		TypeName:      c.ResourceTypeName(),
+		Pattern:       c.ResourceNamePattern(),
+		Segments:      segments,
+		ParentPattern: c.ParentPattern,
+	}
+	if c.HasParent() {
+		data.Parent = segments[:len(segments)-2]
+	}
+
+	var buf bytes.Buffer
+	if err := resourceNameTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated code: %v", err)
+	}
+
+	_, err = w.Write(src)
+	return err
+}
+
+// formatExpr returns the Go expression formatting the segments of a resource
+// name held by a receiver named `n`, e.g. `"projects/" + n.Project`.
+func formatExpr(segments []patternSegment) string {
+	var (
+		parts   []string
+		literal string
+	)
+	for i, s := range segments {
+		if i > 0 {
+			literal += "/"
+		}
+		if !s.IsVariable() {
+			literal += s.Literal
+			continue
+		}
+		if literal != "" {
+			parts = append(parts, fmt.Sprintf("%q", literal))
+			literal = ""
+		}
+		parts = append(parts, "n."+s.FieldName())
+	}
+	if literal != "" {
+		parts = append(parts, fmt.Sprintf("%q", literal))
+	}
+
+	return strings.Join(parts, " + ")
+}
+
+var resourceNameTemplate = template.Must(template.New("resourcename").Funcs(template.FuncMap{
+	"formatExpr": formatExpr,
+}).Parse(`// Code generated by aip-resource-proto-gen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/resourcename"
+)
+
+// {{ .Type }}Pattern is the pattern of the {{ .TypeName }} resource name.
+const {{ .Type }}Pattern = "{{ .Pattern }}"
+
+// {{ .Type }} is the parsed resource name of a {{ .TypeName }} resource.
+type {{ .Type }} struct {
+{{- range .Variables }}
+	{{ .FieldName }} string
+{{- end }}
+}
+
+// Parse{{ .Type }} parses a resource name following the {{ .Type }}Pattern.
+func Parse{{ .Type }}(name string) ({{ .Type }}, error) {
+	return parse{{ .Type }}(name, false)
+}
+{{ if .Parent }}
+// Parse{{ .Type }}WithWildcards parses a resource name following the
+// {{ .Type }}Pattern like Parse{{ .Type }}, also accepting the {{ "` + "`" + `-` + "`" + `" }} wildcard as
+// the ID of its parents, e.g. to read a resource across parents (AIP-159).
+func Parse{{ .Type }}WithWildcards(name string) ({{ .Type }}, error) {
+	return parse{{ .Type }}(name, true)
+}
+{{ end }}
+func parse{{ .Type }}(name string, wildcards bool) ({{ .Type }}, error) {
+	segments := strings.Split(name, "/")
+	if len(segments) != {{ len .Segments }}
+		{{- range $i, $s := .Segments }}{{ if not $s.IsVariable }} || segments[{{ $i }}] != "{{ $s.Literal }}"{{ end }}{{ end }} {
+		return {{ .Type }}{}, fmt.Errorf("resource name %q does not match pattern %q", name, {{ .Type }}Pattern)
+	}
+
+	n := {{ .Type }}{
+	{{- range $i, $s := .Segments }}
+		{{- if $s.IsVariable }}
+		{{ $s.FieldName }}: segments[{{ $i }}],
+		{{- end }}
+	{{- end }}
+	}
+	if err := n.validate(wildcards); err != nil {
+		return {{ .Type }}{}, fmt.Errorf("resource name %q is invalid: %w", name, err)
+	}
+
+	return n, nil
+}
+
+// Validate checks that every segment of the resource name is a valid resource id.
+func (n {{ .Type }}) Validate() error {
+	return n.validate(false)
+}
+
+// validate checks that every segment of the resource name is a valid resource
+// id, or the wildcard for the parents when wildcards is set.
+func (n {{ .Type }}) validate(wildcards bool) error {
+{{- range .ParentVariables }}
+	if !wildcards || n.{{ .FieldName }} != resourcename.Wildcard {
+		if err := resourcename.ValidateID(n.{{ .FieldName }}); err != nil {
+			return fmt.Errorf("{{ .Variable }}: %w", err)
+		}
+	}
+{{- end }}
+{{- with .IDVariable }}
+	if err := resourcename.ValidateID(n.{{ .FieldName }}); err != nil {
+		return fmt.Errorf("{{ .Variable }}: %w", err)
+	}
+{{- end }}
+	return nil
+}
+{{ if .Parent }}
+// HasWildcard returns whether the ID of a parent of the resource name is the
+// wildcard.
+func (n {{ .Type }}) HasWildcard() bool {
+	return {{ range $i, $v := .ParentVariables }}{{ if $i }} || {{ end }}n.{{ $v.FieldName }} == resourcename.Wildcard{{ end }}
+}
+{{ end }}
+// String formats the resource name following the {{ .Type }}Pattern.
+func (n {{ .Type }}) String() string {
+	return {{ formatExpr .Segments }}
+}
+
+{{ if .Parent -}}
+// Parent returns the name of the parent resource, following the "{{ .ParentPattern }}" pattern.
+func (n {{ .Type }}) Parent() string {
+	return {{ formatExpr .Parent }}
+}
+{{- else -}}
+// Parent returns the name of the parent resource, which is always empty as
+// the resource is top-level.
+func (n {{ .Type }}) Parent() string {
+	return ""
+}
+{{- end }}
+`))
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestGoldenResourceName(t *testing.T) {
+	tests := []struct {
+		golden   string
+		resource string
+		args     []string
+	}{
+		{"organization_name.go.golden", "Organization", nil},
+		{"project_name.go.golden", "Project", []string{"--resource-parent", "organizations/{organization}/folders/{folder}"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.golden, func(t *testing.T) {
+			var buf bytes.Buffer
+			if err := generateResourceName(newConfig(t, tt.resource, tt.args...), &buf); err != nil {
+				t.Fatal(err)
+			}
+			golden(t, tt.golden, buf.Bytes())
+		})
+	}
+}
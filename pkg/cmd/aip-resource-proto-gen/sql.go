@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"io"
+	"text/template"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/sqlstore"
+	"github.com/jhump/protoreflect/desc"
+)
+
+func generateSQL(c *Config, fd *desc.FileDescriptor, w io.Writer) error {
+	dialect, err := sqlstore.DialectByName(c.SQLDialect)
+	if err != nil {
+		return err
+	}
+
+	md := fd.FindMessage(c.Package + "." + c.Resource)
+	if md == nil {
+		return fmt.Errorf("resource message %s not found", c.Resource)
+	}
+
+	table, err := sqlstore.NewTable(md.UnwrapMessage())
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "-- Code generated by aip-resource-proto-gen. DO NOT EDIT.\n")
+	for _, stmt := range table.CreateStatements(dialect) {
+		fmt.Fprintf(w, "\n%s;\n", stmt)
+	}
+
+	return nil
+}
+
+type repositoryData struct {
+	Package  string
+	Resource string
+	Table    string
+}
+
+func generateRepository(c *Config, w io.Writer) error {
+	data := repositoryData{
+		Package:  c.GoPackageName(),
+		Resource: c.Resource,
+		Table:    c.PluralResourceSnakeCase(),
+	}
+
+	var buf bytes.Buffer
+	if err := repositoryTemplate.Execute(&buf, data); err != nil {
+		return err
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return fmt.Errorf("failed to format generated code: %v", err)
+	}
+
+	_, err = w.Write(src)
+	return err
+}
+
+var repositoryTemplate = template.Must(template.New("repository").Parse(`// Code generated by aip-resource-proto-gen. DO NOT EDIT.
+
+package {{ .Package }}
+
+import (
+	"database/sql"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/sqlstore"
+)
+
+// {{ .Resource }}Repository stores {{ .Resource }} resources in the "{{ .Table }}" table.
+type {{ .Resource }}Repository = sqlstore.Repository[*{{ .Resource }}]
+
+// New{{ .Resource }}Repository returns a repository storing {{ .Resource }} resources in db.
+func New{{ .Resource }}Repository(db *sql.DB, dialect sqlstore.Dialect) (*{{ .Resource }}Repository, error) {
+	return sqlstore.NewRepository[*{{ .Resource }}](db, dialect)
+}
+`))
@@ -0,0 +1,97 @@
+// Package etag computes the etags of resources and checks those provided in
+// requests, implementing the optimistic concurrency control of AIP-154.
+package etag
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FieldName is the name of the etag field of resources and Delete requests.
+const FieldName = "etag"
+
+// Compute returns the etag of the resource, a checksum of all its fields but
+// the etag itself. The etag is stable for a given value of the resource.
+func Compute(res proto.Message) (string, error) {
+	m := res.ProtoReflect()
+	if fd := field(m); fd != nil && m.Has(fd) {
+		m = proto.Clone(res).ProtoReflect()
+		m.Clear(fd)
+	}
+
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(m.Interface())
+	if err != nil {
+		return "", fmt.Errorf("failed to compute etag: %v", err)
+	}
+
+	sum := sha256.Sum256(b)
+	return base64.RawURLEncoding.EncodeToString(sum[:16]), nil
+}
+
+// Set sets the etag field of the resource to its computed etag. It is a no-op
+// for resources without an etag field.
+func Set(res proto.Message) error {
+	m := res.ProtoReflect()
+	fd := field(m)
+	if fd == nil {
+		return nil
+	}
+
+	etag, err := Compute(res)
+	if err != nil {
+		return err
+	}
+
+	m.Set(fd, protoreflect.ValueOfString(etag))
+	return nil
+}
+
+// Get returns the value of the etag field of msg, either a resource or a
+// request, or an empty string if msg has no etag field.
+func Get(msg proto.Message) string {
+	m := msg.ProtoReflect()
+	if fd := field(m); fd != nil {
+		return m.Get(fd).String()
+	}
+	return ""
+}
+
+// Check checks that the etag provided by the caller matches the etag of the
+// current value of the resource, i.e. its stored etag field, or its computed
+// etag if it has none. The stored etag is compared rather than recomputed, as
+// the stored value may differ from the one the etag was computed from, e.g.
+// timestamps truncated by the database. An empty etag always matches, as etags
+// are optional in requests. On mismatch, it returns an Aborted status error as
+// mandated by AIP-154.
+func Check(current proto.Message, etag string) error {
+	if etag == "" {
+		return nil
+	}
+
+	want := Get(current)
+	if want == "" {
+		var err error
+		if want, err = Compute(current); err != nil {
+			return status.Error(codes.Internal, err.Error())
+		}
+	}
+
+	if etag != want {
+		return status.Errorf(codes.Aborted, "etag %q does not match the current etag of the resource", etag)
+	}
+	return nil
+}
+
+func field(m protoreflect.Message) protoreflect.FieldDescriptor {
+	fd := m.Descriptor().Fields().ByName(FieldName)
+	if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+		return nil
+	}
+	return fd
+}
@@ -0,0 +1,79 @@
+package etag_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/etag"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/internal/testpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func TestCompute(t *testing.T) {
+	res := &testpb.Project{Name: "organizations/acme/projects/p1", DisplayName: "P1"}
+
+	want, err := etag.Compute(res)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The etag does not depend on the etag field.
+	withEtag := proto.Clone(res).(*testpb.Project)
+	withEtag.Etag = "whatever"
+	if got, err := etag.Compute(withEtag); err != nil || got != want {
+		t.Errorf("Compute() = %q, %v, want %q", got, err, want)
+	}
+
+	changed := proto.Clone(res).(*testpb.Project)
+	changed.DisplayName = "P2"
+	if got, err := etag.Compute(changed); err != nil || got == want {
+		t.Errorf("Compute() = %q, %v, want an etag other than %q", got, err, want)
+	}
+}
+
+func TestCheck(t *testing.T) {
+	res := &testpb.Project{
+		Name:       "organizations/acme/projects/p1",
+		CreateTime: timestamppb.New(time.Date(2024, 1, 2, 3, 4, 5, 123456789, time.UTC)),
+	}
+	if err := etag.Set(res); err != nil {
+		t.Fatal(err)
+	}
+	provided := res.GetEtag()
+
+	// The stored resource, whose timestamps are truncated by the database,
+	// still matches the etag returned on creation.
+	stored := proto.Clone(res).(*testpb.Project)
+	stored.CreateTime = timestamppb.New(stored.GetCreateTime().AsTime().Truncate(time.Microsecond))
+
+	tests := []struct {
+		current proto.Message
+		etag    string
+		want    codes.Code
+	}{
+		{stored, provided, codes.OK},
+		{stored, "", codes.OK},
+		{stored, "stale", codes.Aborted},
+		// Without an etag field, the etag is computed.
+		{&structpb.Value{}, "", codes.OK},
+		{&structpb.Value{}, "stale", codes.Aborted},
+	}
+
+	for _, tt := range tests {
+		if got := status.Code(etag.Check(tt.current, tt.etag)); got != tt.want {
+			t.Errorf("Check(%v, %q) = %s, want %s", tt.current, tt.etag, got, tt.want)
+		}
+	}
+
+	want, err := etag.Compute(&structpb.Value{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := etag.Check(&structpb.Value{}, want); err != nil {
+		t.Errorf("Check() = %v, want the computed etag to match", err)
+	}
+}
@@ -0,0 +1,166 @@
+// Package fieldbehavior enforces the google.api.field_behavior annotations of
+// request messages as described in AIP-203.
+package fieldbehavior
+
+import (
+	"context"
+	"fmt"
+
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// Get returns the field behaviors annotated on the field.
+func Get(fd protoreflect.FieldDescriptor) []annotations.FieldBehavior {
+	behaviors, _ := proto.GetExtension(fd.Options(), annotations.E_FieldBehavior).([]annotations.FieldBehavior)
+	return behaviors
+}
+
+// Has reports whether the field is annotated with any of the given behaviors.
+func Has(fd protoreflect.FieldDescriptor, behaviors ...annotations.FieldBehavior) bool {
+	for _, b := range Get(fd) {
+		for _, want := range behaviors {
+			if b == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ValidateRequired checks that every REQUIRED field of msg is set, recursing
+// into the populated message fields, e.g. the resource of a Create request.
+// It returns an InvalidArgument status error with a BadRequest detail listing
+// a violation per missing field.
+//
+// A message carrying a non-empty `update_mask` other than the wildcard is a
+// partial update: its sibling message fields are not checked, as the fields
+// left out of the mask are not expected to be provided.
+func ValidateRequired(msg proto.Message) error {
+	var violations []*errdetails.BadRequest_FieldViolation
+	validateRequired(msg.ProtoReflect(), "", &violations)
+
+	if len(violations) == 0 {
+		return nil
+	}
+
+	return invalidArgument("missing required fields", violations)
+}
+
+func validateRequired(m protoreflect.Message, prefix string, violations *[]*errdetails.BadRequest_FieldViolation) {
+	partial := isPartialUpdate(m)
+
+	fields := m.Descriptor().Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		path := prefix + string(fd.Name())
+
+		if !m.Has(fd) {
+			if Has(fd, annotations.FieldBehavior_REQUIRED) {
+				*violations = append(*violations, &errdetails.BadRequest_FieldViolation{
+					Field:       path,
+					Description: "required field is not set",
+				})
+			}
+			continue
+		}
+
+		if partial || !isMessage(fd) {
+			continue
+		}
+
+		rangeMessages(m, fd, path, func(m protoreflect.Message, path string) {
+			validateRequired(m, path+".", violations)
+		})
+	}
+}
+
+// ClearOutputOnly clears every OUTPUT_ONLY field of msg, recursing into the
+// populated message fields. Servers call it on input messages so that values
+// provided by callers for output only fields are ignored.
+func ClearOutputOnly(msg proto.Message) {
+	clearOutputOnly(msg.ProtoReflect())
+}
+
+func clearOutputOnly(m protoreflect.Message) {
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		if Has(fd, annotations.FieldBehavior_OUTPUT_ONLY) {
+			m.Clear(fd)
+			return true
+		}
+
+		if isMessage(fd) {
+			rangeMessages(m, fd, "", func(m protoreflect.Message, _ string) {
+				clearOutputOnly(m)
+			})
+		}
+		return true
+	})
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor clearing the OUTPUT_ONLY
+// fields of every request and rejecting those missing REQUIRED fields.
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if msg, ok := req.(proto.Message); ok {
+			ClearOutputOnly(msg)
+			if err := ValidateRequired(msg); err != nil {
+				return nil, err
+			}
+		}
+
+		return handler(ctx, req)
+	}
+}
+
+func isMessage(fd protoreflect.FieldDescriptor) bool {
+	if fd.IsMap() {
+		return fd.MapValue().Message() != nil
+	}
+	return fd.Message() != nil
+}
+
+// rangeMessages calls fn for every message held by the message field fd of m,
+// along with its path: the message itself for singular fields, each element
+// for repeated fields and each value for map fields.
+func rangeMessages(m protoreflect.Message, fd protoreflect.FieldDescriptor, path string, fn func(protoreflect.Message, string)) {
+	v := m.Get(fd)
+	switch {
+	case fd.IsList():
+		l := v.List()
+		for i := 0; i < l.Len(); i++ {
+			fn(l.Get(i).Message(), fmt.Sprintf("%s[%d]", path, i))
+		}
+	case fd.IsMap():
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			fn(v.Message(), fmt.Sprintf("%s[%v]", path, k.Interface()))
+			return true
+		})
+	default:
+		fn(v.Message(), path)
+	}
+}
+
+func isPartialUpdate(m protoreflect.Message) bool {
+	fd := m.Descriptor().Fields().ByName("update_mask")
+	if fd == nil || fd.Message() == nil || fd.Message().FullName() != "google.protobuf.FieldMask" || !m.Has(fd) {
+		return false
+	}
+
+	mask := m.Get(fd).Message()
+	paths := mask.Get(mask.Descriptor().Fields().ByName("paths")).List()
+	return paths.Len() > 0 && !(paths.Len() == 1 && paths.Get(0).String() == "*")
+}
+
+func invalidArgument(msg string, violations []*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(codes.InvalidArgument, msg)
+	if detailed, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations}); err == nil {
+		st = detailed
+	}
+	return st.Err()
+}
@@ -0,0 +1,156 @@
+package fieldbehavior_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/fieldbehavior"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/internal/testpb"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// violations returns the fields of the BadRequest violations of err.
+func violations(t *testing.T, err error) []string {
+	t.Helper()
+
+	if err == nil {
+		return nil
+	}
+	st := status.Convert(err)
+	if st.Code() != codes.InvalidArgument {
+		t.Fatalf("got error %v, want code %s", err, codes.InvalidArgument)
+	}
+
+	var fields []string
+	for _, d := range st.Details() {
+		if br, ok := d.(*errdetails.BadRequest); ok {
+			for _, v := range br.GetFieldViolations() {
+				fields = append(fields, v.GetField())
+			}
+		}
+	}
+	return fields
+}
+
+func TestValidateRequired(t *testing.T) {
+	const parent = "organizations/acme"
+	mask := func(paths ...string) *fieldmaskpb.FieldMask {
+		return &fieldmaskpb.FieldMask{Paths: paths}
+	}
+
+	tests := []struct {
+		name string
+		req  proto.Message
+		want []string
+	}{
+		{
+			name: "missing top-level fields",
+			req:  &testpb.CreateProjectRequest{},
+			want: []string{"parent", "project"},
+		},
+		{
+			name: "complete",
+			req:  &testpb.CreateProjectRequest{Parent: parent, Project: &testpb.Project{Spec: &testpb.Project_Spec{Zone: "eu-west1-a"}}},
+		},
+		{
+			name: "unset nested message",
+			req:  &testpb.CreateProjectRequest{Parent: parent, Project: &testpb.Project{}},
+		},
+		{
+			name: "missing nested field",
+			req:  &testpb.CreateProjectRequest{Parent: parent, Project: &testpb.Project{Spec: &testpb.Project_Spec{DiskSize: 10}}},
+			want: []string{"project.spec.zone"},
+		},
+		{
+			name: "update without mask",
+			req:  &testpb.UpdateProjectRequest{Project: &testpb.Project{Spec: &testpb.Project_Spec{}}},
+			want: []string{"project.spec.zone"},
+		},
+		{
+			name: "update with wildcard mask",
+			req:  &testpb.UpdateProjectRequest{Project: &testpb.Project{Spec: &testpb.Project_Spec{}}, UpdateMask: mask("*")},
+			want: []string{"project.spec.zone"},
+		},
+		{
+			// The fields left out of a partial update_mask are not expected.
+			name: "partial update",
+			req:  &testpb.UpdateProjectRequest{Project: &testpb.Project{Spec: &testpb.Project_Spec{}}, UpdateMask: mask("spec.disk_size")},
+		},
+		{
+			name: "partial update without the resource",
+			req:  &testpb.UpdateProjectRequest{UpdateMask: mask("display_name")},
+			want: []string{"project"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := violations(t, fieldbehavior.ValidateRequired(tt.req)); !slices.Equal(got, tt.want) {
+				t.Errorf("ValidateRequired() violations = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClearOutputOnly(t *testing.T) {
+	now := timestamppb.Now()
+	req := &testpb.CreateProjectRequest{
+		Parent: "organizations/acme",
+		Project: &testpb.Project{
+			Name:       "organizations/acme/projects/p1",
+			CreateTime: now,
+			UpdateTime: now,
+			Uid:        "uid",
+			State:      testpb.Project_ACTIVE,
+			Region:     "eu",
+			Spec:       &testpb.Project_Spec{DiskSize: 10},
+		},
+	}
+
+	fieldbehavior.ClearOutputOnly(req)
+
+	want := &testpb.CreateProjectRequest{
+		Parent: "organizations/acme",
+		Project: &testpb.Project{
+			Name:   "organizations/acme/projects/p1",
+			Region: "eu",
+			Spec:   &testpb.Project_Spec{DiskSize: 10},
+		},
+	}
+	if !proto.Equal(req, want) {
+		t.Errorf("ClearOutputOnly() = %v, want %v", req, want)
+	}
+}
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	ctx := context.Background()
+	intercept := fieldbehavior.UnaryServerInterceptor()
+
+	var handled []any
+	handler := func(ctx context.Context, req any) (any, error) {
+		handled = append(handled, req)
+		return req, nil
+	}
+
+	_, err := intercept(ctx, &testpb.CreateProjectRequest{Project: &testpb.Project{}}, nil, handler)
+	if got := violations(t, err); !slices.Equal(got, []string{"parent"}) {
+		t.Errorf("interceptor violations = %q, want %q", got, []string{"parent"})
+	}
+	if len(handled) > 0 {
+		t.Fatalf("handler called with the invalid request %v", handled[0])
+	}
+
+	req := &testpb.CreateProjectRequest{Parent: "organizations/acme", Project: &testpb.Project{Uid: "uid"}}
+	if _, err := intercept(ctx, req, nil, handler); err != nil {
+		t.Fatal(err)
+	}
+	if len(handled) != 1 || req.GetProject().GetUid() != "" {
+		t.Errorf("handler called with %v, want the request without its output only fields", handled)
+	}
+}
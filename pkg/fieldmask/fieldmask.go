@@ -0,0 +1,410 @@
+// Package fieldmask validates and applies the update_mask of Update methods
+// following AIP-134 and AIP-161, and the read_mask of Get and List methods
+// following AIP-157.
+package fieldmask
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/fieldbehavior"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Wildcard is the field mask path requesting a full replacement of the resource.
+const Wildcard = "*"
+
+// Validate checks that every path of mask refers to an updatable field of the
+// message described by md. Paths may traverse nested messages and end with a
+// map key (e.g. `annotations.owner` or "annotations.`acme.com/owner`"), and
+// the mask may be the single wildcard path `*`. Paths referring to
+// OUTPUT_ONLY, IDENTIFIER or IMMUTABLE fields are rejected.
+func Validate(mask *fieldmaskpb.FieldMask, md protoreflect.MessageDescriptor) error {
+	for _, path := range mask.GetPaths() {
+		if path == Wildcard {
+			if len(mask.GetPaths()) > 1 {
+				return fmt.Errorf("wildcard path %q must be the only path of the field mask", Wildcard)
+			}
+			continue
+		}
+
+		if _, err := resolve(path, md, true); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Apply merges the fields of src selected by mask onto dst as described by
+// AIP-134, where dst is the existing resource and src is the resource given in
+// the update request. Both messages must be of the same type.
+//
+// An empty mask is equivalent to the paths of all the populated fields of src,
+// while the wildcard mask replaces every field of dst. In both cases,
+// OUTPUT_ONLY and IDENTIFIER fields of dst are left untouched, and so are
+// IMMUTABLE fields, which src may only populate with their current value. A
+// field selected by the mask but not populated in src is cleared in dst.
+func Apply(dst, src proto.Message, mask *fieldmaskpb.FieldMask) error {
+	d, s := dst.ProtoReflect(), src.ProtoReflect()
+	md := d.Descriptor()
+	if md.FullName() != s.Descriptor().FullName() {
+		return fmt.Errorf("mismatched message types %s and %s", md.FullName(), s.Descriptor().FullName())
+	}
+
+	if err := Validate(mask, md); err != nil {
+		return err
+	}
+
+	paths := mask.GetPaths()
+	switch {
+	case len(paths) == 0:
+		fields := md.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			if fd := fields.Get(i); s.Has(fd) && updatable(fd) {
+				if immutable(fd) {
+					if err := checkImmutable(d, s, fd); err != nil {
+						return err
+					}
+					continue
+				}
+				copyField(d, s, fd)
+			}
+		}
+	case paths[0] == Wildcard:
+		fields := md.Fields()
+		for i := 0; i < fields.Len(); i++ {
+			if fd := fields.Get(i); updatable(fd) {
+				if immutable(fd) {
+					if err := checkImmutable(d, s, fd); err != nil {
+						return err
+					}
+					continue
+				}
+				copyField(d, s, fd)
+			}
+		}
+	default:
+		for _, path := range paths {
+			segments, err := resolve(path, md, true)
+			if err != nil {
+				return err
+			}
+			applyPath(d, s, segments)
+		}
+	}
+
+	return nil
+}
+
+// Prune clears the fields of msg not selected by the read mask, as described by
+// AIP-157. Paths may select nested fields and map entries like those of an
+// update mask, but may refer to any field. An empty mask or the wildcard mask
+// select every field.
+func Prune(msg proto.Message, mask *fieldmaskpb.FieldMask) error {
+	paths := mask.GetPaths()
+	if len(paths) == 0 || len(paths) == 1 && paths[0] == Wildcard {
+		return nil
+	}
+
+	m := msg.ProtoReflect()
+	root := &selection{}
+	for _, path := range paths {
+		if path == Wildcard {
+			return fmt.Errorf("wildcard path %q must be the only path of the field mask", Wildcard)
+		}
+
+		segments, err := resolve(path, m.Descriptor(), false)
+		if err != nil {
+			return err
+		}
+		root.add(segments)
+	}
+
+	root.prune(m)
+	return nil
+}
+
+// selection is the tree of the fields selected by a read mask. A complete
+// selection selects every field of a message or every entry of a map, while
+// keys holds the selected entries of a map, by the interface of their key.
+type selection struct {
+	complete bool
+	fields   map[protoreflect.FieldNumber]*selection
+	keys     map[any]bool
+}
+
+func (s *selection) add(segments []segment) {
+	for _, seg := range segments {
+		if s.complete {
+			return
+		}
+		if s.fields == nil {
+			s.fields = map[protoreflect.FieldNumber]*selection{}
+		}
+		next, ok := s.fields[seg.field.Number()]
+		if !ok {
+			next = &selection{}
+			s.fields[seg.field.Number()] = next
+		}
+		s = next
+
+		if seg.key != nil {
+			if !s.complete {
+				if s.keys == nil {
+					s.keys = map[any]bool{}
+				}
+				s.keys[seg.key.Interface()] = true
+			}
+			return
+		}
+	}
+
+	s.complete, s.fields, s.keys = true, nil, nil
+}
+
+func (s *selection) prune(m protoreflect.Message) {
+	// Collect the fields first, as messages must not be mutated while ranging.
+	var fields []protoreflect.FieldDescriptor
+	m.Range(func(fd protoreflect.FieldDescriptor, _ protoreflect.Value) bool {
+		fields = append(fields, fd)
+		return true
+	})
+
+	for _, fd := range fields {
+		sub, ok := s.fields[fd.Number()]
+		switch {
+		case !ok:
+			m.Clear(fd)
+		case sub.complete:
+		case fd.IsMap():
+			mv := m.Mutable(fd).Map()
+			var cleared []protoreflect.MapKey
+			mv.Range(func(k protoreflect.MapKey, _ protoreflect.Value) bool {
+				if !sub.keys[k.Interface()] {
+					cleared = append(cleared, k)
+				}
+				return true
+			})
+			for _, k := range cleared {
+				mv.Clear(k)
+			}
+		case fd.Message() != nil && !fd.IsList():
+			sub.prune(m.Mutable(fd).Message())
+		}
+	}
+}
+
+// segment is a resolved component of a field mask path. The key is only set
+// for the last segment of a path selecting a single entry of a map field.
+type segment struct {
+	field protoreflect.FieldDescriptor
+	key   *protoreflect.MapKey
+}
+
+// resolve resolves path against md. With forUpdate, paths referring to fields
+// that are not updatable are rejected.
+func resolve(path string, md protoreflect.MessageDescriptor, forUpdate bool) ([]segment, error) {
+	parts, err := splitPath(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var segments []segment
+	for i := 0; i < len(parts); i++ {
+		if md == nil {
+			return nil, fmt.Errorf("path %q: field %q cannot have sub-fields", path, parts[i-1])
+		}
+
+		fd := md.Fields().ByName(protoreflect.Name(parts[i]))
+		if fd == nil {
+			return nil, fmt.Errorf("path %q: unknown field %q in %s", path, parts[i], md.FullName())
+		}
+
+		if forUpdate && !updatable(fd) {
+			return nil, fmt.Errorf("path %q: field %q is not updatable", path, fd.Name())
+		}
+		if forUpdate && immutable(fd) {
+			return nil, fmt.Errorf("path %q: field %q is immutable", path, fd.Name())
+		}
+
+		seg := segment{field: fd}
+		md = nil
+
+		switch {
+		case fd.IsMap():
+			if i+1 < len(parts) {
+				i++
+				key, err := parseMapKey(fd.MapKey(), parts[i])
+				if err != nil {
+					return nil, fmt.Errorf("path %q: %v", path, err)
+				}
+				seg.key = &key
+				if i+1 < len(parts) {
+					return nil, fmt.Errorf("path %q: map entry %q cannot have sub-fields", path, parts[i])
+				}
+			}
+		case fd.IsList():
+		case fd.Message() != nil:
+			md = fd.Message()
+		}
+
+		segments = append(segments, seg)
+	}
+
+	return segments, nil
+}
+
+// splitPath splits a field mask path on dots, keeping the content of
+// backtick-quoted segments verbatim as described in AIP-161.
+func splitPath(path string) ([]string, error) {
+	var (
+		parts   []string
+		current strings.Builder
+		quoted  bool
+	)
+
+	for i := 0; i < len(path); i++ {
+		switch ch := path[i]; {
+		case ch == '`':
+			quoted = !quoted
+		case ch == '.' && !quoted:
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(ch)
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("path %q: unterminated backtick", path)
+	}
+	parts = append(parts, current.String())
+
+	for _, p := range parts {
+		if p == "" {
+			return nil, fmt.Errorf("path %q: empty segment", path)
+		}
+	}
+
+	return parts, nil
+}
+
+func parseMapKey(fd protoreflect.FieldDescriptor, s string) (protoreflect.MapKey, error) {
+	var v protoreflect.Value
+	switch fd.Kind() {
+	case protoreflect.StringKind:
+		v = protoreflect.ValueOfString(s)
+	case protoreflect.BoolKind:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return protoreflect.MapKey{}, fmt.Errorf("invalid bool map key %q", s)
+		}
+		v = protoreflect.ValueOfBool(b)
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		n, err := strconv.ParseInt(s, 10, 32)
+		if err != nil {
+			return protoreflect.MapKey{}, fmt.Errorf("invalid int32 map key %q", s)
+		}
+		v = protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind:
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return protoreflect.MapKey{}, fmt.Errorf("invalid int64 map key %q", s)
+		}
+		v = protoreflect.ValueOfInt64(n)
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		n, err := strconv.ParseUint(s, 10, 32)
+		if err != nil {
+			return protoreflect.MapKey{}, fmt.Errorf("invalid uint32 map key %q", s)
+		}
+		v = protoreflect.ValueOfUint32(uint32(n))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		n, err := strconv.ParseUint(s, 10, 64)
+		if err != nil {
+			return protoreflect.MapKey{}, fmt.Errorf("invalid uint64 map key %q", s)
+		}
+		v = protoreflect.ValueOfUint64(n)
+	default:
+		return protoreflect.MapKey{}, fmt.Errorf("unsupported map key kind %s", fd.Kind())
+	}
+
+	return v.MapKey(), nil
+}
+
+func applyPath(dst, src protoreflect.Message, segments []segment) {
+	seg := segments[0]
+	fd := seg.field
+
+	if seg.key != nil {
+		if v := src.Get(fd).Map().Get(*seg.key); v.IsValid() {
+			dst.Mutable(fd).Map().Set(*seg.key, cloneValue(fd.MapValue(), v))
+		} else if dst.Has(fd) {
+			dst.Mutable(fd).Map().Clear(*seg.key)
+		}
+		return
+	}
+
+	if len(segments) == 1 {
+		copyField(dst, src, fd)
+		return
+	}
+
+	if !dst.Has(fd) && !src.Has(fd) {
+		return
+	}
+	applyPath(dst.Mutable(fd).Message(), src.Get(fd).Message(), segments[1:])
+}
+
+// copyField replaces the value of fd in dst by a deep copy of its value in src.
+func copyField(dst, src protoreflect.Message, fd protoreflect.FieldDescriptor) {
+	dst.Clear(fd)
+	if !src.Has(fd) {
+		return
+	}
+
+	switch v := src.Get(fd); {
+	case fd.IsList():
+		l, dl := v.List(), dst.Mutable(fd).List()
+		for i := 0; i < l.Len(); i++ {
+			dl.Append(cloneValue(fd, l.Get(i)))
+		}
+	case fd.IsMap():
+		dm := dst.Mutable(fd).Map()
+		v.Map().Range(func(k protoreflect.MapKey, v protoreflect.Value) bool {
+			dm.Set(k, cloneValue(fd.MapValue(), v))
+			return true
+		})
+	default:
+		dst.Set(fd, cloneValue(fd, v))
+	}
+}
+
+// cloneValue returns a deep copy of a singular value of the field fd.
+func cloneValue(fd protoreflect.FieldDescriptor, v protoreflect.Value) protoreflect.Value {
+	if fd.Message() == nil {
+		return v
+	}
+	return protoreflect.ValueOfMessage(proto.Clone(v.Message().Interface()).ProtoReflect())
+}
+
+func updatable(fd protoreflect.FieldDescriptor) bool {
+	return !fieldbehavior.Has(fd, annotations.FieldBehavior_OUTPUT_ONLY, annotations.FieldBehavior_IDENTIFIER)
+}
+
+func immutable(fd protoreflect.FieldDescriptor) bool {
+	return fieldbehavior.Has(fd, annotations.FieldBehavior_IMMUTABLE)
+}
+
+// checkImmutable fails if src populates the immutable field fd with a value
+// other than its value in dst.
+func checkImmutable(dst, src protoreflect.Message, fd protoreflect.FieldDescriptor) error {
+	if !src.Has(fd) || src.Get(fd).Equal(dst.Get(fd)) {
+		return nil
+	}
+	return fmt.Errorf("field %q is immutable", fd.Name())
+}
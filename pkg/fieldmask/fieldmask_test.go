@@ -0,0 +1,220 @@
+package fieldmask_test
+
+import (
+	"testing"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/fieldmask"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/internal/testpb"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func mask(paths ...string) *fieldmaskpb.FieldMask {
+	return &fieldmaskpb.FieldMask{Paths: paths}
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		paths   []string
+		wantErr bool
+	}{
+		{nil, false},
+		{[]string{"*"}, false},
+		{[]string{"display_name", "tier"}, false},
+		{[]string{"spec.disk_size"}, false},
+		{[]string{"labels"}, false},
+		{[]string{"labels.env"}, false},
+		{[]string{"annotations.`acme.com/owner`"}, false},
+		{[]string{"tags"}, false},
+		{[]string{"*", "display_name"}, true},
+		{[]string{"unknown"}, true},
+		{[]string{"spec.unknown"}, true},
+		{[]string{"display_name.sub"}, true},
+		{[]string{"labels.env.sub"}, true},
+		{[]string{"tags.sub"}, true},
+		{[]string{"spec..disk_size"}, true},
+		{[]string{"annotations.`acme.com/owner"}, true},
+		// OUTPUT_ONLY, IDENTIFIER and IMMUTABLE fields cannot be updated.
+		{[]string{"create_time"}, true},
+		{[]string{"name"}, true},
+		{[]string{"uid"}, true},
+		{[]string{"region"}, true},
+	}
+
+	md := (&testpb.Project{}).ProtoReflect().Descriptor()
+	for _, tt := range tests {
+		if err := fieldmask.Validate(mask(tt.paths...), md); (err != nil) != tt.wantErr {
+			t.Errorf("Validate(%q) = %v, want error %t", tt.paths, err, tt.wantErr)
+		}
+	}
+}
+
+func TestApply(t *testing.T) {
+	createTime := timestamppb.Now()
+	existing := func() *testpb.Project {
+		return &testpb.Project{
+			Name:        "organizations/acme/projects/p1",
+			DisplayName: "P1",
+			CreateTime:  createTime,
+			Region:      "eu",
+			Replicas:    3,
+			Labels:      map[string]string{"env": "prod", "team": "infra"},
+			Spec:        &testpb.Project_Spec{DiskSize: 10},
+			Tags:        []string{"a"},
+		}
+	}
+
+	tests := []struct {
+		name    string
+		src     *testpb.Project
+		paths   []string
+		want    *testpb.Project
+		wantErr bool
+	}{
+		{
+			name:  "empty mask updates the populated fields",
+			src:   &testpb.Project{DisplayName: "P2", Tags: []string{"b", "c"}},
+			paths: nil,
+			want: &testpb.Project{
+				Name: "organizations/acme/projects/p1", DisplayName: "P2", CreateTime: createTime, Region: "eu", Replicas: 3,
+				Labels: map[string]string{"env": "prod", "team": "infra"}, Spec: &testpb.Project_Spec{DiskSize: 10}, Tags: []string{"b", "c"},
+			},
+		},
+		{
+			name:  "empty mask leaves the output only fields",
+			src:   &testpb.Project{Name: "organizations/acme/projects/p2", CreateTime: timestamppb.New(createTime.AsTime().Add(1))},
+			paths: nil,
+			want:  existing(),
+		},
+		{
+			name:  "mask clears the fields missing from src",
+			src:   &testpb.Project{DisplayName: "P2"},
+			paths: []string{"display_name", "replicas"},
+			want: &testpb.Project{
+				Name: "organizations/acme/projects/p1", DisplayName: "P2", CreateTime: createTime, Region: "eu",
+				Labels: map[string]string{"env": "prod", "team": "infra"}, Spec: &testpb.Project_Spec{DiskSize: 10}, Tags: []string{"a"},
+			},
+		},
+		{
+			name:  "nested field",
+			src:   &testpb.Project{Spec: &testpb.Project_Spec{DiskSize: 20}},
+			paths: []string{"spec.disk_size"},
+			want: &testpb.Project{
+				Name: "organizations/acme/projects/p1", DisplayName: "P1", CreateTime: createTime, Region: "eu", Replicas: 3,
+				Labels: map[string]string{"env": "prod", "team": "infra"}, Spec: &testpb.Project_Spec{DiskSize: 20}, Tags: []string{"a"},
+			},
+		},
+		{
+			name:  "map entries",
+			src:   &testpb.Project{Labels: map[string]string{"env": "dev", "tier": "gold"}},
+			paths: []string{"labels.env", "labels.team", "labels.tier"},
+			want: &testpb.Project{
+				Name: "organizations/acme/projects/p1", DisplayName: "P1", CreateTime: createTime, Region: "eu", Replicas: 3,
+				Labels: map[string]string{"env": "dev", "tier": "gold"}, Spec: &testpb.Project_Spec{DiskSize: 10}, Tags: []string{"a"},
+			},
+		},
+		{
+			name:  "wildcard replaces the resource, with the current immutable values",
+			src:   &testpb.Project{DisplayName: "P2", Region: "eu"},
+			paths: []string{"*"},
+			want:  &testpb.Project{Name: "organizations/acme/projects/p1", DisplayName: "P2", CreateTime: createTime, Region: "eu"},
+		},
+		{
+			name:  "wildcard keeps the immutable fields",
+			src:   &testpb.Project{DisplayName: "P2"},
+			paths: []string{"*"},
+			want:  &testpb.Project{Name: "organizations/acme/projects/p1", DisplayName: "P2", CreateTime: createTime, Region: "eu"},
+		},
+		{
+			name:    "wildcard changing an immutable field",
+			src:     &testpb.Project{Region: "us"},
+			paths:   []string{"*"},
+			wantErr: true,
+		},
+		{
+			name:    "empty mask changing an immutable field",
+			src:     &testpb.Project{Region: "us"},
+			paths:   nil,
+			wantErr: true,
+		},
+		{
+			name:    "immutable path",
+			src:     &testpb.Project{Region: "eu"},
+			paths:   []string{"region"},
+			wantErr: true,
+		},
+		{
+			name:    "output only path",
+			src:     &testpb.Project{},
+			paths:   []string{"create_time"},
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dst := existing()
+			err := fieldmask.Apply(dst, tt.src, mask(tt.paths...))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Apply() = %v, want error %t", err, tt.wantErr)
+			}
+			if err == nil && !proto.Equal(dst, tt.want) {
+				t.Errorf("Apply() = %v, want %v", dst, tt.want)
+			}
+		})
+	}
+
+	// The applied values are copies of those of src.
+	dst, src := existing(), &testpb.Project{Spec: &testpb.Project_Spec{DiskSize: 20}}
+	if err := fieldmask.Apply(dst, src, mask("spec")); err != nil {
+		t.Fatal(err)
+	}
+	src.Spec.DiskSize = 30
+	if dst.GetSpec().GetDiskSize() != 20 {
+		t.Errorf("Apply() shares the spec of src")
+	}
+
+	if err := fieldmask.Apply(dst, &testpb.Project_Spec{}, nil); err == nil {
+		t.Errorf("Apply() of another message type succeeded")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	project := func() *testpb.Project {
+		return &testpb.Project{
+			Name:        "organizations/acme/projects/p1",
+			DisplayName: "P1",
+			Labels:      map[string]string{"env": "prod", "team": "infra"},
+			Spec:        &testpb.Project_Spec{DiskSize: 10},
+			Tags:        []string{"a"},
+		}
+	}
+
+	tests := []struct {
+		paths   []string
+		want    *testpb.Project
+		wantErr bool
+	}{
+		{nil, project(), false},
+		{[]string{"*"}, project(), false},
+		{[]string{"name", "display_name"}, &testpb.Project{Name: "organizations/acme/projects/p1", DisplayName: "P1"}, false},
+		{[]string{"labels.env", "spec.disk_size"}, &testpb.Project{Labels: map[string]string{"env": "prod"}, Spec: &testpb.Project_Spec{DiskSize: 10}}, false},
+		{[]string{"labels.env", "labels"}, &testpb.Project{Labels: map[string]string{"env": "prod", "team": "infra"}}, false},
+		{[]string{"tags", "create_time"}, &testpb.Project{Tags: []string{"a"}}, false},
+		{[]string{"name", "*"}, nil, true},
+		{[]string{"unknown"}, nil, true},
+	}
+
+	for _, tt := range tests {
+		got := project()
+		err := fieldmask.Prune(got, mask(tt.paths...))
+		if (err != nil) != tt.wantErr {
+			t.Errorf("Prune(%q) = %v, want error %t", tt.paths, err, tt.wantErr)
+			continue
+		}
+		if err == nil && !proto.Equal(got, tt.want) {
+			t.Errorf("Prune(%q) = %v, want %v", tt.paths, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,412 @@
+// Package filtering parses the filter of List methods following the AIP-160
+// grammar into an Expr tree.
+package filtering
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Expr is a node of a parsed filter.
+type Expr interface {
+	isExpr()
+}
+
+// And matches when all of its expressions match. Both explicit `AND` and
+// whitespace separated sequences parse to And.
+type And struct {
+	Exprs []Expr
+}
+
+// Or matches when any of its expressions matches.
+type Or struct {
+	Exprs []Expr
+}
+
+// Not matches when its expression does not match, from either `NOT` or the
+// `-` prefix.
+type Not struct {
+	Expr Expr
+}
+
+// Restriction compares a field, possibly traversing messages and maps, to a
+// value, e.g. `labels.env = "prod"` or `create_time > "2024-01-01T00:00:00Z"`.
+type Restriction struct {
+	// Path of the field, e.g. [labels env] for `labels.env`. Segments may be
+	// quoted with backticks, e.g. "labels.`acme.com/env`".
+	Path []string
+	// Comparator is one of =, !=, <, <=, >, >= and : (has).
+	Comparator Comparator
+	// Arg is the value compared to the field.
+	Arg Arg
+}
+
+// Arg is the value of a Restriction.
+type Arg struct {
+	// Value is the unquoted value.
+	Value string
+	// Quoted is whether the value was a quoted string literal, as opposed to
+	// a bare text like a number, a boolean or an enum value.
+	Quoted bool
+}
+
+// Comparator of a Restriction.
+type Comparator string
+
+const (
+	Equals        Comparator = "="
+	NotEquals     Comparator = "!="
+	LessThan      Comparator = "<"
+	LessEquals    Comparator = "<="
+	GreaterThan   Comparator = ">"
+	GreaterEquals Comparator = ">="
+	Has           Comparator = ":"
+)
+
+func (And) isExpr()         {}
+func (Or) isExpr()          {}
+func (Not) isExpr()         {}
+func (Restriction) isExpr() {}
+
+// Parse parses a filter, returning a nil Expr for an empty filter. Global
+// restrictions (bare values without a field) and function calls are not
+// supported.
+func Parse(filter string) (Expr, error) {
+	tokens, err := lex(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{filter: filter, tokens: tokens}
+	expr, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+
+	if !p.done() {
+		return nil, p.errorf("unexpected %q", p.peek().text)
+	}
+
+	return expr, nil
+}
+
+type tokenKind int
+
+const (
+	tokenText tokenKind = iota
+	tokenString
+	tokenComparator
+	tokenLParen
+	tokenRParen
+	tokenComma
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	pos  int
+}
+
+func lex(s string) ([]token, error) {
+	var tokens []token
+
+	for i := 0; i < len(s); {
+		ch := s[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\n' || ch == '\r':
+			i++
+		case ch == '(':
+			tokens = append(tokens, token{tokenLParen, "(", i})
+			i++
+		case ch == ')':
+			tokens = append(tokens, token{tokenRParen, ")", i})
+			i++
+		case ch == ',':
+			tokens = append(tokens, token{tokenComma, ",", i})
+			i++
+		case ch == '"' || ch == '\'':
+			value, n, err := lexString(s[i:])
+			if err != nil {
+				return nil, fmt.Errorf("filter %q: %v at position %d", s, err, i)
+			}
+			tokens = append(tokens, token{tokenString, value, i})
+			i += n
+		case strings.ContainsRune("=!<>:", rune(ch)):
+			op := string(ch)
+			if i+1 < len(s) && s[i+1] == '=' && ch != '=' && ch != ':' {
+				op += "="
+			}
+			if op == "!" {
+				return nil, fmt.Errorf("filter %q: unexpected %q at position %d", s, op, i)
+			}
+			tokens = append(tokens, token{tokenComparator, op, i})
+			i += len(op)
+		default:
+			start := i
+			for i < len(s) && !strings.ContainsRune(" \t\n\r(),\"'=!<>:", rune(s[i])) {
+				i++
+			}
+			tokens = append(tokens, token{tokenText, s[start:i], start})
+		}
+	}
+
+	return tokens, nil
+}
+
+// lexString lexes a quoted string literal at the start of s, returning its
+// unquoted value and its length in s.
+func lexString(s string) (string, int, error) {
+	quote := s[0]
+
+	var b strings.Builder
+	for i := 1; i < len(s); i++ {
+		switch ch := s[i]; ch {
+		case quote:
+			return b.String(), i + 1, nil
+		case '\\':
+			if i+1 == len(s) {
+				return "", 0, fmt.Errorf("unterminated string")
+			}
+			i++
+			switch esc := s[i]; esc {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(esc)
+			}
+		default:
+			b.WriteByte(ch)
+		}
+	}
+
+	return "", 0, fmt.Errorf("unterminated string")
+}
+
+type parser struct {
+	filter string
+	tokens []token
+	pos    int
+}
+
+func (p *parser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *parser) peek() token {
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.tokens[p.pos]
+	p.pos++
+	return t
+}
+
+func (p *parser) peekKeyword(keyword string) bool {
+	return !p.done() && p.peek().kind == tokenText && p.peek().text == keyword
+}
+
+func (p *parser) errorf(format string, args ...any) error {
+	pos := len(p.filter)
+	if !p.done() {
+		pos = p.peek().pos
+	}
+	return fmt.Errorf("filter %q: %s at position %d", p.filter, fmt.Sprintf(format, args...), pos)
+}
+
+// expression : sequence { "AND" sequence }
+func (p *parser) expression() (Expr, error) {
+	var exprs []Expr
+	for {
+		seq, err := p.sequence()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, seq)
+
+		if !p.peekKeyword("AND") {
+			break
+		}
+		p.next()
+	}
+
+	return and(exprs), nil
+}
+
+// sequence : factor { factor }
+func (p *parser) sequence() (Expr, error) {
+	var exprs []Expr
+	for {
+		factor, err := p.factor()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, factor)
+
+		if p.done() || p.peek().kind == tokenRParen || p.peekKeyword("AND") {
+			break
+		}
+	}
+
+	return and(exprs), nil
+}
+
+// factor : term { "OR" term }
+func (p *parser) factor() (Expr, error) {
+	var exprs []Expr
+	for {
+		term, err := p.term()
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, term)
+
+		if !p.peekKeyword("OR") {
+			break
+		}
+		p.next()
+	}
+
+	if len(exprs) == 1 {
+		return exprs[0], nil
+	}
+	return Or{Exprs: exprs}, nil
+}
+
+// term : [ "NOT" | "-" ] simple
+func (p *parser) term() (Expr, error) {
+	if p.done() {
+		return nil, p.errorf("unexpected end of filter")
+	}
+
+	if p.peekKeyword("NOT") {
+		p.next()
+		simple, err := p.simple()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: simple}, nil
+	}
+
+	if t := p.peek(); t.kind == tokenText && len(t.text) > 1 && t.text[0] == '-' {
+		p.tokens[p.pos].text = t.text[1:]
+		p.tokens[p.pos].pos++
+		simple, err := p.simple()
+		if err != nil {
+			return nil, err
+		}
+		return Not{Expr: simple}, nil
+	}
+
+	return p.simple()
+}
+
+// simple : restriction | "(" expression ")"
+func (p *parser) simple() (Expr, error) {
+	if p.done() {
+		return nil, p.errorf("unexpected end of filter")
+	}
+
+	if p.peek().kind == tokenLParen {
+		p.next()
+		expr, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if p.done() || p.peek().kind != tokenRParen {
+			return nil, p.errorf("expected \")\"")
+		}
+		p.next()
+		return expr, nil
+	}
+
+	return p.restriction()
+}
+
+// restriction : member comparator arg
+func (p *parser) restriction() (Expr, error) {
+	member := p.peek()
+	if member.kind != tokenText {
+		return nil, p.errorf("expected a field, got %q", member.text)
+	}
+	p.next()
+
+	if !p.done() && p.peek().kind == tokenLParen {
+		p.pos--
+		return nil, p.errorf("function calls are not supported")
+	}
+
+	if p.done() || p.peek().kind != tokenComparator {
+		p.pos--
+		return nil, p.errorf("global restriction %q is not supported, expected a comparison", member.text)
+	}
+	comparator := Comparator(p.next().text)
+
+	if p.done() {
+		return nil, p.errorf("expected a value")
+	}
+	arg := p.next()
+	if arg.kind != tokenText && arg.kind != tokenString {
+		p.pos--
+		return nil, p.errorf("expected a value, got %q", arg.text)
+	}
+
+	path, ok := splitMember(member.text)
+	if !ok {
+		p.pos -= 3
+		return nil, p.errorf("invalid field %q", member.text)
+	}
+
+	return Restriction{
+		Path:       path,
+		Comparator: comparator,
+		Arg:        Arg{Value: arg.text, Quoted: arg.kind == tokenString},
+	}, nil
+}
+
+// splitMember splits a field on dots, keeping the content of backtick-quoted
+// segments verbatim so that map keys may contain dots, e.g.
+// "labels.`acme.com/env`".
+func splitMember(member string) ([]string, bool) {
+	var (
+		path    []string
+		current strings.Builder
+		quoted  bool
+	)
+	for i := 0; i < len(member); i++ {
+		switch ch := member[i]; {
+		case ch == '`':
+			quoted = !quoted
+		case ch == '.' && !quoted:
+			path = append(path, current.String())
+			current.Reset()
+		default:
+			current.WriteByte(ch)
+		}
+	}
+	path = append(path, current.String())
+
+	if quoted {
+		return nil, false
+	}
+	for _, segment := range path {
+		if segment == "" {
+			return nil, false
+		}
+	}
+
+	return path, true
+}
+
+func and(exprs []Expr) Expr {
+	if len(exprs) == 1 {
+		return exprs[0]
+	}
+	return And{Exprs: exprs}
+}
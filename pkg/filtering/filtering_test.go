@@ -0,0 +1,90 @@
+package filtering
+
+import (
+	"reflect"
+	"testing"
+)
+
+func restriction(field string, comparator Comparator, value string, quoted bool) Restriction {
+	path, _ := splitMember(field)
+	return Restriction{Path: path, Comparator: comparator, Arg: Arg{Value: value, Quoted: quoted}}
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		filter string
+		want   Expr
+	}{
+		{"", nil},
+		{"  ", nil},
+		{`display_name = "acme"`, restriction("display_name", Equals, "acme", true)},
+		{`display_name='acme'`, restriction("display_name", Equals, "acme", true)},
+		{`replicas >= 3`, restriction("replicas", GreaterEquals, "3", false)},
+		{`replicas != 3`, restriction("replicas", NotEquals, "3", false)},
+		{`state = ACTIVE`, restriction("state", Equals, "ACTIVE", false)},
+		{`expire_time:*`, restriction("expire_time", Has, "*", false)},
+		{`labels.env = "prod"`, restriction("labels.env", Equals, "prod", true)},
+		{"labels.`acme.com/env` = \"prod\"", Restriction{Path: []string{"labels", "acme.com/env"}, Comparator: Equals, Arg: Arg{Value: "prod", Quoted: true}}},
+		{`display_name = "say \"hi\"\n"`, restriction("display_name", Equals, "say \"hi\"\n", true)},
+		{
+			`a = 1 AND b = 2`,
+			And{Exprs: []Expr{restriction("a", Equals, "1", false), restriction("b", Equals, "2", false)}},
+		},
+		{
+			`a = 1 b = 2`,
+			And{Exprs: []Expr{restriction("a", Equals, "1", false), restriction("b", Equals, "2", false)}},
+		},
+		{
+			// OR binds tighter than AND.
+			`a = 1 OR b = 2 AND c = 3`,
+			And{Exprs: []Expr{
+				Or{Exprs: []Expr{restriction("a", Equals, "1", false), restriction("b", Equals, "2", false)}},
+				restriction("c", Equals, "3", false),
+			}},
+		},
+		{
+			`(a = 1 AND b = 2) OR c = 3`,
+			Or{Exprs: []Expr{
+				And{Exprs: []Expr{restriction("a", Equals, "1", false), restriction("b", Equals, "2", false)}},
+				restriction("c", Equals, "3", false),
+			}},
+		},
+		{`NOT a = 1`, Not{Expr: restriction("a", Equals, "1", false)}},
+		{`-a = 1`, Not{Expr: restriction("a", Equals, "1", false)}},
+		{`NOT (a = 1)`, Not{Expr: restriction("a", Equals, "1", false)}},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.filter)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.filter, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %#v, want %#v", tt.filter, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, filter := range []string{
+		`prod`,
+		`display_name`,
+		`display_name =`,
+		`display_name = "acme`,
+		`display_name ! "acme"`,
+		`a = 1 AND`,
+		`a = 1 OR`,
+		`(a = 1`,
+		`a = 1)`,
+		`a = (1)`,
+		`regex(display_name, "a.*")`,
+		`labels..env = "prod"`,
+		"labels.`env = \"prod\"",
+		`NOT`,
+	} {
+		if expr, err := Parse(filter); err == nil {
+			t.Errorf("Parse(%q) = %#v, want an error", filter, expr)
+		}
+	}
+}
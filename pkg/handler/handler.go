@@ -0,0 +1,689 @@
+// Package handler is a reference implementation of the AIP standard methods of
+// a resource stored in a sqlstore.Repository. The methods take the generated
+// request messages and read their standard fields by reflection, so that a
+// service implementation only has to delegate to them:
+//
+//	func (s *server) GetProject(ctx context.Context, req *acmev1.GetProjectRequest) (*acmev1.Project, error) {
+//		return s.projects.Get(ctx, req)
+//	}
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/etag"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/fieldbehavior"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/fieldmask"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/resourcename"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/sqlstore"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/watch"
+	"github.com/stoewer/go-strcase"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+)
+
+// Handler implements the standard methods of the resources of type T.
+type Handler[T proto.Message] struct {
+	repo *sqlstore.Repository[T]
+
+	// pattern is the pattern of the resource names, e.g.
+	// `organizations/{organization}/projects/{project}`, if the resource has one.
+	pattern string
+	// collection is the collection identifier of the resource, e.g. `projects`.
+	collection string
+	// singular is the snake case singular of the resource, e.g. `project`,
+	// naming the resource field of Create and Update requests.
+	singular string
+	// plural is the snake case plural of the resource, e.g. `projects`,
+	// naming the resources field of List responses.
+	plural string
+	// children are the collections of the child resources.
+	children []Children
+	// basicView is the read mask of the BASIC resource view.
+	basicView *fieldmaskpb.FieldMask
+	// hub broadcasts the changes of the resources to the watchers, if set.
+	hub *watch.Hub[T]
+}
+
+// Children is a collection of child resources of a parent resource, deleted
+// along with their parent when it is deleted with force (AIP-135). Handlers
+// are the Children of the parent of their resources.
+type Children interface {
+	// HasChildren returns whether the collection has resources under parent.
+	HasChildren(ctx context.Context, parent string) (bool, error)
+	// DeleteChildren deletes the resources of the collection under parent,
+	// along with their own children.
+	DeleteChildren(ctx context.Context, parent string) error
+}
+
+// New returns a handler of the resources stored in repo.
+func New[T proto.Message](repo *sqlstore.Repository[T]) (*Handler[T], error) {
+	var zero T
+	md := zero.ProtoReflect().Descriptor()
+
+	rd, _ := proto.GetExtension(md.Options(), annotations.E_Resource).(*annotations.ResourceDescriptor)
+	if rd == nil {
+		return nil, fmt.Errorf("message %s is not a resource", md.FullName())
+	}
+
+	singular := rd.GetSingular()
+	if singular == "" {
+		singular = strcase.LowerCamelCase(string(md.Name()))
+	}
+
+	// Unless overridden, the BASIC view leaves out the repeated and map fields,
+	// the ones most likely to be large.
+	basicView := &fieldmaskpb.FieldMask{}
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		if fd := fields.Get(i); !fd.IsList() && !fd.IsMap() {
+			basicView.Paths = append(basicView.Paths, string(fd.Name()))
+		}
+	}
+
+	var pattern string
+	if len(rd.GetPattern()) > 0 {
+		pattern = rd.GetPattern()[0]
+	}
+
+	return &Handler[T]{
+		repo:       repo,
+		pattern:    pattern,
+		collection: strcase.LowerCamelCase(rd.GetPlural()),
+		singular:   strcase.SnakeCase(singular),
+		plural:     strcase.SnakeCase(rd.GetPlural()),
+		basicView:  basicView,
+	}, nil
+}
+
+// SetBasicView sets the read mask of the BASIC resource view (AIP-157), which
+// by default selects all the fields but the repeated and map ones.
+func (h *Handler[T]) SetBasicView(mask *fieldmaskpb.FieldMask) {
+	h.basicView = mask
+}
+
+// AddChildren declares collections of child resources of the resources of h.
+// Delete then fails with FailedPrecondition when the resource has children,
+// unless the request has force set, in which case they are deleted along with
+// it.
+func (h *Handler[T]) AddChildren(children ...Children) {
+	h.children = append(h.children, children...)
+}
+
+// HasChildren implements Children.
+func (h *Handler[T]) HasChildren(ctx context.Context, parent string) (bool, error) {
+	resources, _, err := h.repo.List(ctx, sqlstore.ListOptions{Parent: parent, PageSize: 1})
+	if err != nil {
+		return false, err
+	}
+	return len(resources) > 0, nil
+}
+
+// DeleteChildren implements Children. The children are deleted in a single
+// transaction, which is the one of the parent deletion when the repositories
+// share their database.
+func (h *Handler[T]) DeleteChildren(ctx context.Context, parent string) error {
+	return h.repo.InTx(ctx, func(ctx context.Context) error {
+		for {
+			// Always list the first page, as the deletions shift the next ones.
+			resources, _, err := h.repo.List(ctx, sqlstore.ListOptions{Parent: parent, PageSize: sqlstore.MaxPageSize})
+			if err != nil {
+				return err
+			}
+			if len(resources) == 0 {
+				return nil
+			}
+
+			for _, res := range resources {
+				name := getString(res, "name")
+				for _, c := range h.children {
+					if err := c.DeleteChildren(ctx, name); err != nil {
+						return err
+					}
+				}
+				if err := h.repo.Delete(ctx, name, ""); err != nil && status.Code(err) != codes.NotFound {
+					return err
+				}
+				h.publish(ctx, watch.Deleted, res)
+			}
+		}
+	})
+}
+
+// SetHub sets the hub to which the changes of the resources made through h are
+// published, and from which the Watch method streams them.
+func (h *Handler[T]) SetHub(hub *watch.Hub[T]) {
+	h.hub = hub
+}
+
+// Get implements the Get method (AIP-131). The returned resource is pruned
+// according to the read_mask or the view of the request (AIP-157), the FULL
+// view being the default.
+func (h *Handler[T]) Get(ctx context.Context, req proto.Message) (T, error) {
+	var zero T
+
+	mask, err := h.readMask(req, false)
+	if err != nil {
+		return zero, err
+	}
+
+	res, err := h.repo.Get(ctx, getString(req, "name"))
+	if err != nil {
+		return zero, err
+	}
+
+	if err := fieldmask.Prune(res, mask); err != nil {
+		return zero, status.Errorf(codes.InvalidArgument, "read_mask: %v", err)
+	}
+	return res, nil
+}
+
+// List implements the List method (AIP-132), filling the resources and the
+// next_page_token of the response res. The parent may have `-` wildcard IDs to
+// list the resources across parents (AIP-159). The listed resources are pruned
+// according to the read_mask or the view of the request (AIP-157), the BASIC
+// view being the default.
+//
+// The skip of the request and the total_size of the response are supported
+// (AIP-158), while the unreachable field of the response is always empty as
+// all the resources are stored in the same database (AIP-217).
+func (h *Handler[T]) List(ctx context.Context, req, res proto.Message) error {
+	mask, err := h.readMask(req, true)
+	if err != nil {
+		return err
+	}
+
+	opts := sqlstore.ListOptions{
+		Parent:    getString(req, "parent"),
+		PageSize:  getInt32(req, "page_size"),
+		PageToken: getString(req, "page_token"),
+		Filter:    getString(req, "filter"),
+		OrderBy:   getString(req, "order_by"),
+		Skip:      getInt32(req, "skip"),
+		Query:     getString(req, "query"),
+	}
+
+	resources, next, err := h.repo.List(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	out := res.ProtoReflect()
+	fd := out.Descriptor().Fields().ByName(protoreflect.Name(h.plural))
+	if fd == nil || !fd.IsList() {
+		return status.Errorf(codes.Internal, "response %s has no repeated %s field", out.Descriptor().FullName(), h.plural)
+	}
+
+	list := out.Mutable(fd).List()
+	for _, r := range resources {
+		if err := fieldmask.Prune(r, mask); err != nil {
+			return status.Errorf(codes.InvalidArgument, "read_mask: %v", err)
+		}
+		list.Append(protoreflect.ValueOfMessage(r.ProtoReflect()))
+	}
+	setString(res, "next_page_token", next)
+
+	if m, fd := field(res, "total_size", protoreflect.Int32Kind); fd != nil {
+		n, err := h.repo.Count(ctx, opts)
+		if err != nil {
+			return err
+		}
+		m.Set(fd, protoreflect.ValueOfInt32(int32(n)))
+	}
+
+	return nil
+}
+
+// Search implements the Search custom method, listing the resources matching
+// the free-text query of the request like List does.
+func (h *Handler[T]) Search(ctx context.Context, req, res proto.Message) error {
+	return h.List(ctx, req, res)
+}
+
+// Create implements the Create method (AIP-133). The resource name is made of
+// the parent and the requested id, or a generated UUID when the request has
+// none. An empty uid field of the resource is set to a generated UUID.
+//
+// When the request has validate_only set, the request is validated and the
+// resource that would have been created is returned without storing it
+// (AIP-163).
+func (h *Handler[T]) Create(ctx context.Context, req proto.Message) (T, error) {
+	var zero T
+
+	if err := fieldbehavior.ValidateRequired(req); err != nil {
+		return zero, err
+	}
+
+	res, err := h.resource(req)
+	if err != nil {
+		return zero, err
+	}
+	fieldbehavior.ClearOutputOnly(res)
+
+	id := getString(req, h.singular+"_id")
+	if id == "" {
+		id = newUUID()
+	}
+	if err := resourcename.ValidateID(id); err != nil {
+		return zero, status.Errorf(codes.InvalidArgument, "%s_id: %v", h.singular, err)
+	}
+
+	parent := getString(req, "parent")
+	if resourcename.HasWildcard(parent) {
+		return zero, status.Errorf(codes.InvalidArgument, "parent %q must not have wildcards", parent)
+	}
+
+	name := h.collection + "/" + id
+	if parent != "" {
+		name = parent + "/" + name
+	}
+	setString(res, "name", name)
+	if getString(res, "uid") == "" {
+		setString(res, "uid", newUUID())
+	}
+
+	if validateOnly(req) {
+		if _, err := h.repo.Get(ctx, name); err == nil {
+			return zero, status.Errorf(codes.AlreadyExists, "resource %q already exists", name)
+		} else if status.Code(err) != codes.NotFound {
+			return zero, err
+		}
+		return res, nil
+	}
+
+	return h.create(ctx, res)
+}
+
+// Update implements the Update method (AIP-134), applying the update_mask of
+// the request onto the stored resource. The etag of the resource, if provided,
+// must match the stored etag. A missing resource is created when the request
+// has allow_missing set, provided that its name is a valid name of the
+// collection, with an empty uid field set to a generated UUID like Create does.
+//
+// When the request has validate_only set, the updated resource is returned
+// without storing it (AIP-163).
+func (h *Handler[T]) Update(ctx context.Context, req proto.Message) (T, error) {
+	var zero T
+
+	if err := fieldbehavior.ValidateRequired(req); err != nil {
+		return zero, err
+	}
+
+	res, err := h.resource(req)
+	if err != nil {
+		return zero, err
+	}
+	fieldbehavior.ClearOutputOnly(res)
+
+	name := getString(res, "name")
+	existing, err := h.repo.Get(ctx, name)
+	if status.Code(err) == codes.NotFound && getBool(req, "allow_missing") {
+		if err := h.validateName(name); err != nil {
+			return zero, err
+		}
+		if getString(res, "uid") == "" {
+			setString(res, "uid", newUUID())
+		}
+
+		if validateOnly(req) {
+			return res, nil
+		}
+		return h.create(ctx, res)
+	} else if err != nil {
+		return zero, err
+	}
+
+	provided := etag.Get(res)
+	if err := etag.Check(existing, provided); err != nil {
+		return zero, err
+	}
+
+	var mask *fieldmaskpb.FieldMask
+	if m := getMessage(req, "update_mask"); m != nil {
+		mask = &fieldmaskpb.FieldMask{}
+		proto.Merge(mask, m)
+	}
+	if err := fieldmask.Apply(existing, res, mask); err != nil {
+		return zero, status.Errorf(codes.InvalidArgument, "update_mask: %v", err)
+	}
+
+	if validateOnly(req) {
+		return existing, nil
+	}
+
+	// Make the update conditional on the etag read above, so that concurrent
+	// updates are detected.
+	if provided == "" {
+		provided = etag.Get(existing)
+	}
+	setString(existing, etag.FieldName, provided)
+
+	updated, err := h.repo.Update(ctx, existing)
+	if err != nil {
+		return zero, err
+	}
+	h.publish(ctx, watch.Modified, updated)
+
+	return updated, nil
+}
+
+// Delete implements the Delete method (AIP-135). The etag of the request, if
+// provided, must match the stored etag. Deleting a missing resource succeeds
+// when the request has allow_missing set. A resource with children, see
+// AddChildren, is only deleted, along with its children, when the request has
+// force set. The children are checked, or deleted after the etag check, in the
+// transaction deleting the resource when their repositories share its
+// database.
+//
+// When the request has validate_only set, the request is validated without
+// deleting the resource (AIP-163).
+func (h *Handler[T]) Delete(ctx context.Context, req proto.Message) (*emptypb.Empty, error) {
+	name := getString(req, "name")
+
+	existing, err := h.repo.Get(ctx, name)
+	if status.Code(err) == codes.NotFound && getBool(req, "allow_missing") {
+		return &emptypb.Empty{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	provided := getString(req, etag.FieldName)
+	if err := etag.Check(existing, provided); err != nil {
+		return nil, err
+	}
+
+	if provided == "" {
+		provided = etag.Get(existing)
+	}
+	force := getBool(req, "force")
+	err = h.repo.InTx(ctx, func(ctx context.Context) error {
+		// The children are checked in the transaction deleting the resource,
+		// so that the deletion is atomic with the check.
+		if !force {
+			for _, c := range h.children {
+				has, err := c.HasChildren(ctx, name)
+				if err != nil {
+					return err
+				}
+				if has {
+					return status.Errorf(codes.FailedPrecondition, "resource %q has children, set force to delete them", name)
+				}
+			}
+		}
+
+		if validateOnly(req) {
+			return nil
+		}
+
+		// The resource is deleted first so that its etag is checked before
+		// any of its children is deleted.
+		if err := h.repo.Delete(ctx, name, provided); err != nil {
+			return err
+		}
+		if force {
+			for _, c := range h.children {
+				if err := c.DeleteChildren(ctx, name); err != nil {
+					return err
+				}
+			}
+		}
+		h.publish(ctx, watch.Deleted, existing)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &emptypb.Empty{}, nil
+}
+
+// Transition implements a state-transition custom method (AIP-216), moving the
+// resource named by the request to the state of the given name, e.g.
+// `SUSPENDED`, from one of the from states, e.g. `ACTIVE`, or from any other
+// state if none is given. The transition fails with FailedPrecondition when
+// the resource is already in the target state or in none of the from states.
+// The etag of the request, if provided, must match the stored etag.
+//
+// When the request has validate_only set, the resource in its new state is
+// returned without storing it (AIP-163).
+func (h *Handler[T]) Transition(ctx context.Context, req proto.Message, state string, from ...string) (T, error) {
+	var zero T
+
+	name := getString(req, "name")
+	res, err := h.repo.Get(ctx, name)
+	if err != nil {
+		return zero, err
+	}
+
+	if err := etag.Check(res, getString(req, etag.FieldName)); err != nil {
+		return zero, err
+	}
+
+	m, fd := field(res, "state", protoreflect.EnumKind)
+	if fd == nil {
+		return zero, status.Errorf(codes.Internal, "resource %s has no state", m.Descriptor().FullName())
+	}
+	value := fd.Enum().Values().ByName(protoreflect.Name(state))
+	if value == nil {
+		return zero, status.Errorf(codes.Internal, "resource %s has no %s state", m.Descriptor().FullName(), state)
+	}
+
+	var current string
+	if v := fd.Enum().Values().ByNumber(m.Get(fd).Enum()); v != nil {
+		current = string(v.Name())
+	}
+	if current == state {
+		return zero, status.Errorf(codes.FailedPrecondition, "resource %q is already %s", name, state)
+	}
+	if len(from) > 0 && !slices.Contains(from, current) {
+		return zero, status.Errorf(codes.FailedPrecondition, "resource %q cannot move from %s to %s, only from %s",
+			name, current, state, strings.Join(from, " or "))
+	}
+
+	// The update is conditional on the etag read above, if any, so that of
+	// concurrent transitions only one succeeds.
+	m.Set(fd, protoreflect.ValueOfEnum(value.Number()))
+
+	if validateOnly(req) {
+		return res, nil
+	}
+
+	updated, err := h.repo.Update(ctx, res)
+	if err != nil {
+		return zero, err
+	}
+	h.publish(ctx, watch.Modified, updated)
+
+	return updated, nil
+}
+
+// Watch implements the Watch methods of a resource, by name, and of the
+// resources of a collection, by parent, calling send with their changes
+// until ctx is done or send fails. The request may have a resume_token to
+// resume the watch after a previous change. It requires a hub, see SetHub.
+func (h *Handler[T]) Watch(ctx context.Context, req proto.Message, send func(watch.Event[T]) error) error {
+	if h.hub == nil {
+		return status.Error(codes.Unimplemented, "watching the resources is not supported")
+	}
+
+	match := func(T) bool { return true }
+	if _, fd := field(req, "name", protoreflect.StringKind); fd != nil {
+		name := getString(req, "name")
+		match = func(res T) bool { return getString(res, "name") == name }
+	} else if parent := getString(req, "parent"); parent != "" {
+		match = func(res T) bool { return isParent(parent, getString(res, "name")) }
+	}
+
+	return h.hub.Watch(ctx, getString(req, "resume_token"), match, send)
+}
+
+func (h *Handler[T]) create(ctx context.Context, res T) (T, error) {
+	created, err := h.repo.Create(ctx, res)
+	if err != nil {
+		return created, err
+	}
+	h.publish(ctx, watch.Added, created)
+
+	return created, nil
+}
+
+// publish publishes a change to the hub, if any, once the transaction of ctx
+// is committed.
+func (h *Handler[T]) publish(ctx context.Context, typ watch.EventType, res T) {
+	if h.hub != nil {
+		h.repo.AfterCommit(ctx, func() { h.hub.Publish(typ, res) })
+	}
+}
+
+// isParent returns whether parent, which may have wildcard IDs, is the parent
+// of the resource name.
+func isParent(parent, name string) bool {
+	parentSegments := strings.Split(parent, "/")
+	segments := strings.Split(name, "/")
+	if len(segments) != len(parentSegments)+2 {
+		return false
+	}
+
+	for i, segment := range parentSegments {
+		if segment != segments[i] && segment != resourcename.Wildcard {
+			return false
+		}
+	}
+	return true
+}
+
+// validateName checks that name is the name of a resource of the collection,
+// e.g. with the ID of a missing resource to create on Update.
+func (h *Handler[T]) validateName(name string) error {
+	if resourcename.HasWildcard(name) {
+		return status.Errorf(codes.InvalidArgument, "name %q must not have wildcards", name)
+	}
+
+	segments := strings.Split(name, "/")
+	if h.pattern != "" {
+		if _, err := resourcename.Parse(h.pattern, name); err != nil {
+			return status.Errorf(codes.InvalidArgument, "name: %v", err)
+		}
+	} else if len(segments) < 2 || segments[len(segments)-2] != h.collection {
+		return status.Errorf(codes.InvalidArgument, "name %q is not in the %s collection", name, h.collection)
+	}
+
+	if err := resourcename.ValidateID(segments[len(segments)-1]); err != nil {
+		return status.Errorf(codes.InvalidArgument, "name: %v", err)
+	}
+	return nil
+}
+
+// resource returns a copy of the resource field of a Create or Update request.
+func (h *Handler[T]) resource(req proto.Message) (T, error) {
+	var zero T
+
+	m := getMessage(req, h.singular)
+	if m == nil {
+		return zero, status.Errorf(codes.InvalidArgument, "%s is required", h.singular)
+	}
+
+	res, ok := proto.Clone(m).(T)
+	if !ok {
+		return zero, status.Errorf(codes.Internal, "field %s of %s is not a %T", h.singular, req.ProtoReflect().Descriptor().FullName(), zero)
+	}
+	return res, nil
+}
+
+// readMask returns the mask of the fields to return from the read_mask or the
+// view field of a Get or List request, nil selecting every field.
+func (h *Handler[T]) readMask(req proto.Message, list bool) (*fieldmaskpb.FieldMask, error) {
+	if m := getMessage(req, "read_mask"); m != nil {
+		mask := &fieldmaskpb.FieldMask{}
+		proto.Merge(mask, m)
+
+		// Validate the mask upfront, as List has nothing to prune on empty pages.
+		var zero T
+		if err := fieldmask.Prune(zero.ProtoReflect().New().Interface(), mask); err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "read_mask: %v", err)
+		}
+		return mask, nil
+	}
+
+	m, fd := field(req, "view", protoreflect.EnumKind)
+	if fd == nil {
+		return nil, nil
+	}
+
+	// The values of the generated view enums are UNSPECIFIED, BASIC and FULL.
+	switch view := m.Get(fd).Enum(); {
+	case view == 1, view == 0 && list:
+		return h.basicView, nil
+	case view == 0, view == 2:
+		return nil, nil
+	default:
+		return nil, status.Errorf(codes.InvalidArgument, "unknown view %d", view)
+	}
+}
+
+func validateOnly(req proto.Message) bool {
+	return getBool(req, "validate_only")
+}
+
+func field(msg proto.Message, name string, kind protoreflect.Kind) (protoreflect.Message, protoreflect.FieldDescriptor) {
+	m := msg.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName(protoreflect.Name(name))
+	if fd == nil || fd.Kind() != kind || fd.IsList() || fd.IsMap() {
+		return m, nil
+	}
+	return m, fd
+}
+
+func getString(msg proto.Message, name string) string {
+	if m, fd := field(msg, name, protoreflect.StringKind); fd != nil {
+		return m.Get(fd).String()
+	}
+	return ""
+}
+
+func setString(msg proto.Message, name, value string) {
+	if m, fd := field(msg, name, protoreflect.StringKind); fd != nil {
+		m.Set(fd, protoreflect.ValueOfString(value))
+	}
+}
+
+func getInt32(msg proto.Message, name string) int32 {
+	if m, fd := field(msg, name, protoreflect.Int32Kind); fd != nil {
+		return int32(m.Get(fd).Int())
+	}
+	return 0
+}
+
+func getBool(msg proto.Message, name string) bool {
+	if m, fd := field(msg, name, protoreflect.BoolKind); fd != nil {
+		return m.Get(fd).Bool()
+	}
+	return false
+}
+
+func getMessage(msg proto.Message, name string) proto.Message {
+	if m, fd := field(msg, name, protoreflect.MessageKind); fd != nil && m.Has(fd) {
+		return m.Get(fd).Message().Interface()
+	}
+	return nil
+}
+
+// newUUID returns a random version 4 UUID.
+func newUUID() string {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		panic(err)
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,538 @@
+package handler
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/internal/testpb"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/sqlstore"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/fieldmaskpb"
+	_ "modernc.org/sqlite"
+)
+
+// newTestHandler returns a handler of the resources of type T stored in an
+// in-memory SQLite database.
+func newTestHandler[T proto.Message](t *testing.T) *Handler[T] {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection would open its own in-memory database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	repo, err := sqlstore.NewRepository[T](db, sqlstore.SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range repo.Table().CreateStatements(sqlstore.SQLite) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, err := New(repo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func assertCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("got error %v, want code %s", err, want)
+	}
+}
+
+func TestCreate(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler[*testpb.Project](t)
+
+	const parent = "organizations/acme"
+	if _, err := h.repo.Create(ctx, &testpb.Project{Name: parent + "/projects/existing"}); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		req      *testpb.CreateProjectRequest
+		wantName string
+		want     codes.Code
+	}{
+		{"with id", &testpb.CreateProjectRequest{Parent: parent, ProjectId: "p1", Project: &testpb.Project{}}, parent + "/projects/p1", codes.OK},
+		{"without id", &testpb.CreateProjectRequest{Parent: parent, Project: &testpb.Project{}}, "", codes.OK},
+		{"invalid id", &testpb.CreateProjectRequest{Parent: parent, ProjectId: "P1", Project: &testpb.Project{}}, "", codes.InvalidArgument},
+		{"id too long", &testpb.CreateProjectRequest{Parent: parent, ProjectId: strings.Repeat("p", 64), Project: &testpb.Project{}}, "", codes.InvalidArgument},
+		{"missing parent", &testpb.CreateProjectRequest{ProjectId: "p2", Project: &testpb.Project{}}, "", codes.InvalidArgument},
+		{"wildcard parent", &testpb.CreateProjectRequest{Parent: "organizations/-", ProjectId: "p2", Project: &testpb.Project{}}, "", codes.InvalidArgument},
+		{"missing resource", &testpb.CreateProjectRequest{Parent: parent, ProjectId: "p2"}, "", codes.InvalidArgument},
+		{"already exists", &testpb.CreateProjectRequest{Parent: parent, ProjectId: "existing", Project: &testpb.Project{}}, "", codes.AlreadyExists},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// The provided uid is an output only field, always generated.
+			if tt.req.Project != nil {
+				tt.req.Project.Uid = "provided"
+			}
+
+			res, err := h.Create(ctx, tt.req)
+			assertCode(t, err, tt.want)
+			if err != nil {
+				return
+			}
+
+			if tt.wantName != "" && res.GetName() != tt.wantName {
+				t.Errorf("Create() named the resource %q, want %q", res.GetName(), tt.wantName)
+			}
+			if !strings.HasPrefix(res.GetName(), parent+"/projects/") {
+				t.Errorf("Create() named the resource %q, want one of the projects of %s", res.GetName(), parent)
+			}
+			if res.GetUid() == "" || res.GetUid() == "provided" {
+				t.Errorf("Create() = %v, want a generated uid", res)
+			}
+			if _, err := h.repo.Get(ctx, res.GetName()); err != nil {
+				t.Errorf("Get(%q) = %v after its creation", res.GetName(), err)
+			}
+		})
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler[*testpb.Project](t)
+
+	created, err := h.repo.Create(ctx, &testpb.Project{
+		Name:        "organizations/acme/projects/p1",
+		DisplayName: "P1",
+		Region:      "eu",
+		Replicas:    3,
+		Labels:      map[string]string{"env": "prod"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := created.GetName()
+
+	tests := []struct {
+		name  string
+		req   *testpb.UpdateProjectRequest
+		check func(*testpb.Project) bool
+		want  codes.Code
+	}{
+		{
+			name: "mask",
+			req: &testpb.UpdateProjectRequest{
+				Project:    &testpb.Project{Name: name, DisplayName: "P2", Replicas: 5},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"display_name"}},
+			},
+			check: func(p *testpb.Project) bool { return p.GetDisplayName() == "P2" && p.GetReplicas() == 3 },
+		},
+		{
+			name:  "without mask",
+			req:   &testpb.UpdateProjectRequest{Project: &testpb.Project{Name: name, Replicas: 5}},
+			check: func(p *testpb.Project) bool { return p.GetDisplayName() == "P2" && p.GetReplicas() == 5 },
+		},
+		{
+			name: "map entry",
+			req: &testpb.UpdateProjectRequest{
+				Project:    &testpb.Project{Name: name, Labels: map[string]string{"team": "infra"}},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"labels.team"}},
+			},
+			check: func(p *testpb.Project) bool {
+				return p.GetLabels()["env"] == "prod" && p.GetLabels()["team"] == "infra"
+			},
+		},
+		{
+			name: "immutable field",
+			req: &testpb.UpdateProjectRequest{
+				Project:    &testpb.Project{Name: name, Region: "us"},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"region"}},
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "unknown field",
+			req: &testpb.UpdateProjectRequest{
+				Project:    &testpb.Project{Name: name},
+				UpdateMask: &fieldmaskpb.FieldMask{Paths: []string{"unknown"}},
+			},
+			want: codes.InvalidArgument,
+		},
+		{
+			name: "stale etag",
+			req:  &testpb.UpdateProjectRequest{Project: &testpb.Project{Name: name, DisplayName: "P3", Etag: created.GetEtag()}},
+			want: codes.Aborted,
+		},
+		{
+			name: "missing",
+			req:  &testpb.UpdateProjectRequest{Project: &testpb.Project{Name: "organizations/acme/projects/missing"}},
+			want: codes.NotFound,
+		},
+	}
+
+	etag := created.GetEtag()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := h.Update(ctx, tt.req)
+			assertCode(t, err, tt.want)
+			if err != nil {
+				return
+			}
+
+			stored, err := h.repo.Get(ctx, name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !proto.Equal(res, stored) || !tt.check(stored) {
+				t.Errorf("Update() = %v, stored %v", res, stored)
+			}
+			if stored.GetEtag() == etag {
+				t.Errorf("Update() kept the etag %q", etag)
+			}
+			etag = stored.GetEtag()
+		})
+	}
+}
+
+func TestReadMask(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler[*testpb.Project](t)
+
+	const parent = "organizations/acme"
+	for _, id := range []string{"p1", "p2"} {
+		if _, err := h.repo.Create(ctx, &testpb.Project{Name: parent + "/projects/" + id, DisplayName: id, Replicas: 3}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		paths []string
+		want  *testpb.Project
+		code  codes.Code
+	}{
+		{[]string{"name", "display_name"}, &testpb.Project{Name: parent + "/projects/p1", DisplayName: "p1"}, codes.OK},
+		{[]string{"replicas"}, &testpb.Project{Replicas: 3}, codes.OK},
+		{[]string{"unknown"}, nil, codes.InvalidArgument},
+		{[]string{"*", "name"}, nil, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		mask := &fieldmaskpb.FieldMask{Paths: tt.paths}
+
+		got, err := h.Get(ctx, &testpb.GetProjectRequest{Name: parent + "/projects/p1", ReadMask: mask})
+		assertCode(t, err, tt.code)
+		if err == nil && !proto.Equal(got, tt.want) {
+			t.Errorf("Get(%q) = %v, want %v", tt.paths, got, tt.want)
+		}
+
+		res := &testpb.ListProjectResponse{}
+		err = h.List(ctx, &testpb.ListProjectRequest{Parent: parent, OrderBy: "name", ReadMask: mask}, res)
+		assertCode(t, err, tt.code)
+		if err == nil && (len(res.GetProjects()) != 2 || !proto.Equal(res.GetProjects()[0], tt.want)) {
+			t.Errorf("List(%q) = %v, want %v first", tt.paths, res.GetProjects(), tt.want)
+		}
+	}
+}
+
+func TestView(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler[*testpb.Database](t)
+
+	const parent = "organizations/acme/projects/p1"
+	created, err := h.repo.Create(ctx, &testpb.Database{
+		Name:        parent + "/databases/d1",
+		DisplayName: "D1",
+		Annotations: map[string]string{"acme.com/owner": "infra"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	basic := proto.Clone(created).(*testpb.Database)
+	basic.Annotations = nil
+
+	tests := []struct {
+		view     testpb.DatabaseView
+		wantGet  *testpb.Database
+		wantList *testpb.Database
+		code     codes.Code
+	}{
+		// Get defaults to the FULL view and List to the BASIC one, which
+		// leaves out the repeated and map fields.
+		{testpb.DatabaseView_DATABASE_VIEW_UNSPECIFIED, created, basic, codes.OK},
+		{testpb.DatabaseView_DATABASE_VIEW_BASIC, basic, basic, codes.OK},
+		{testpb.DatabaseView_DATABASE_VIEW_FULL, created, created, codes.OK},
+		{testpb.DatabaseView(7), nil, nil, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		got, err := h.Get(ctx, &testpb.GetDatabaseRequest{Name: created.GetName(), View: tt.view})
+		assertCode(t, err, tt.code)
+		if err == nil && !proto.Equal(got, tt.wantGet) {
+			t.Errorf("Get(%s) = %v, want %v", tt.view, got, tt.wantGet)
+		}
+
+		res := &testpb.ListDatabaseResponse{}
+		err = h.List(ctx, &testpb.ListDatabaseRequest{Parent: parent, View: tt.view}, res)
+		assertCode(t, err, tt.code)
+		if err == nil && (len(res.GetDatabases()) != 1 || !proto.Equal(res.GetDatabases()[0], tt.wantList)) {
+			t.Errorf("List(%s) = %v, want %v", tt.view, res.GetDatabases(), tt.wantList)
+		}
+	}
+
+	// The BASIC view may be overridden.
+	h.SetBasicView(&fieldmaskpb.FieldMask{Paths: []string{"name"}})
+	got, err := h.Get(ctx, &testpb.GetDatabaseRequest{Name: created.GetName(), View: testpb.DatabaseView_DATABASE_VIEW_BASIC})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (&testpb.Database{Name: created.GetName()}); !proto.Equal(got, want) {
+		t.Errorf("Get(BASIC) = %v, want %v", got, want)
+	}
+}
+
+func TestValidateOnly(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler[*testpb.Project](t)
+
+	created, err := h.repo.Create(ctx, &testpb.Project{Name: "organizations/acme/projects/p1", DisplayName: "P1", State: testpb.Project_ACTIVE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := created.GetName()
+
+	tests := []struct {
+		name string
+		call func() error
+		want codes.Code
+	}{
+		{"create", func() error {
+			_, err := h.Create(ctx, &testpb.CreateProjectRequest{Parent: "organizations/acme", ProjectId: "p2", Project: &testpb.Project{}, ValidateOnly: true})
+			return err
+		}, codes.OK},
+		{"create existing", func() error {
+			_, err := h.Create(ctx, &testpb.CreateProjectRequest{Parent: "organizations/acme", ProjectId: "p1", Project: &testpb.Project{}, ValidateOnly: true})
+			return err
+		}, codes.AlreadyExists},
+		{"create invalid", func() error {
+			_, err := h.Create(ctx, &testpb.CreateProjectRequest{Parent: "organizations/acme", ProjectId: "P2", Project: &testpb.Project{}, ValidateOnly: true})
+			return err
+		}, codes.InvalidArgument},
+		{"update", func() error {
+			_, err := h.Update(ctx, &testpb.UpdateProjectRequest{Project: &testpb.Project{Name: name, DisplayName: "P2"}, ValidateOnly: true})
+			return err
+		}, codes.OK},
+		{"update stale", func() error {
+			_, err := h.Update(ctx, &testpb.UpdateProjectRequest{Project: &testpb.Project{Name: name, Etag: "stale"}, ValidateOnly: true})
+			return err
+		}, codes.Aborted},
+		{"update missing", func() error {
+			_, err := h.Update(ctx, &testpb.UpdateProjectRequest{Project: &testpb.Project{Name: "organizations/acme/projects/p2"}, AllowMissing: true, ValidateOnly: true})
+			return err
+		}, codes.OK},
+		{"delete", func() error {
+			_, err := h.Delete(ctx, &testpb.DeleteProjectRequest{Name: name, ValidateOnly: true})
+			return err
+		}, codes.OK},
+		{"delete stale", func() error {
+			_, err := h.Delete(ctx, &testpb.DeleteProjectRequest{Name: name, Etag: "stale", ValidateOnly: true})
+			return err
+		}, codes.Aborted},
+		{"transition", func() error {
+			_, err := h.Transition(ctx, &testpb.SuspendProjectRequest{Name: name, ValidateOnly: true}, "SUSPENDED")
+			return err
+		}, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assertCode(t, tt.call(), tt.want)
+
+			// Nothing is ever stored.
+			stored, err := h.repo.Get(ctx, name)
+			if err != nil || !proto.Equal(stored, created) {
+				t.Errorf("Get(%q) = %v, %v, want %v", name, stored, err, created)
+			}
+			_, err = h.repo.Get(ctx, "organizations/acme/projects/p2")
+			assertCode(t, err, codes.NotFound)
+		})
+	}
+}
+
+// children is a collection of children deleting, along with them, the
+// resource named orphan from the database of the parent.
+type children struct {
+	h       *Handler[*testpb.Project]
+	orphan  string
+	err     error
+	deleted []string
+	// checkedInTx is whether HasChildren was last called in a transaction.
+	checkedInTx bool
+}
+
+func (c *children) HasChildren(ctx context.Context, parent string) (bool, error) {
+	// AfterCommit defers the function in a transaction.
+	called := false
+	c.h.repo.AfterCommit(ctx, func() { called = true })
+	c.checkedInTx = !called
+	return true, nil
+}
+
+func (c *children) DeleteChildren(ctx context.Context, parent string) error {
+	if err := c.h.repo.Delete(ctx, c.orphan, ""); err != nil {
+		return err
+	}
+	c.deleted = append(c.deleted, parent)
+	return c.err
+}
+
+func TestDeleteForce(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler[*testpb.Project](t)
+
+	const orphan = "organizations/other/projects/orphan"
+	c := &children{h: h, orphan: orphan}
+	h.AddChildren(c)
+
+	create := func(name string) *testpb.Project {
+		t.Helper()
+		res, err := h.repo.Create(ctx, &testpb.Project{Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res
+	}
+	p1 := create("organizations/acme/projects/p1")
+	create(orphan)
+
+	_, err := h.Delete(ctx, &testpb.DeleteProjectRequest{Name: p1.GetName()})
+	assertCode(t, err, codes.FailedPrecondition)
+	if !c.checkedInTx {
+		t.Errorf("children checked outside of the transaction deleting their parent")
+	}
+	_, err = h.Delete(ctx, &testpb.DeleteProjectRequest{Name: p1.GetName(), ValidateOnly: true})
+	assertCode(t, err, codes.FailedPrecondition)
+
+	// The etag is checked before any child is deleted.
+	_, err = h.Delete(ctx, &testpb.DeleteProjectRequest{Name: p1.GetName(), Etag: "stale", Force: true})
+	assertCode(t, err, codes.Aborted)
+	if len(c.deleted) > 0 {
+		t.Fatalf("children of %v deleted despite the etag mismatch", c.deleted)
+	}
+
+	// A failure to delete the children rolls back the whole deletion.
+	c.err = errors.New("failed")
+	if _, err := h.Delete(ctx, &testpb.DeleteProjectRequest{Name: p1.GetName(), Etag: p1.GetEtag(), Force: true}); !errors.Is(err, c.err) {
+		t.Fatalf("Delete() = %v, want %v", err, c.err)
+	}
+	for _, name := range []string{p1.GetName(), orphan} {
+		if _, err := h.repo.Get(ctx, name); err != nil {
+			t.Errorf("Get(%q) = %v after a failed deletion", name, err)
+		}
+	}
+
+	c.err = nil
+	if _, err := h.Delete(ctx, &testpb.DeleteProjectRequest{Name: p1.GetName(), Etag: p1.GetEtag(), Force: true}); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{p1.GetName(), orphan} {
+		_, err := h.repo.Get(ctx, name)
+		assertCode(t, err, codes.NotFound)
+	}
+}
+
+func TestTransition(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler[*testpb.Project](t)
+
+	created, err := h.repo.Create(ctx, &testpb.Project{Name: "organizations/acme/projects/p1", State: testpb.Project_ACTIVE})
+	if err != nil {
+		t.Fatal(err)
+	}
+	name := created.GetName()
+
+	tests := []struct {
+		name  string
+		req   proto.Message
+		state string
+		from  []string
+		want  codes.Code
+	}{
+		{"already in the state", &testpb.ResumeProjectRequest{Name: name}, "ACTIVE", nil, codes.FailedPrecondition},
+		{"from another state", &testpb.SuspendProjectRequest{Name: name}, "SUSPENDED", []string{"STATE_UNSPECIFIED"}, codes.FailedPrecondition},
+		{"stale etag", &testpb.SuspendProjectRequest{Name: name, Etag: "stale"}, "SUSPENDED", nil, codes.Aborted},
+		{"missing", &testpb.SuspendProjectRequest{Name: "organizations/acme/projects/missing"}, "SUSPENDED", nil, codes.NotFound},
+		{"validate only", &testpb.SuspendProjectRequest{Name: name, ValidateOnly: true}, "SUSPENDED", nil, codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := h.Transition(ctx, tt.req, tt.state, tt.from...)
+			assertCode(t, err, tt.want)
+		})
+	}
+
+	if res, err := h.repo.Get(ctx, name); err != nil || res.GetState() != testpb.Project_ACTIVE {
+		t.Fatalf("Get() = %v, %v, want the project still ACTIVE", res, err)
+	}
+
+	suspended, err := h.Transition(ctx, &testpb.SuspendProjectRequest{Name: name, Etag: created.GetEtag()}, "SUSPENDED", "ACTIVE")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suspended.GetState() != testpb.Project_SUSPENDED || suspended.GetEtag() == created.GetEtag() {
+		t.Errorf("Transition() = %v, want SUSPENDED with a new etag", suspended)
+	}
+
+	// The etag read before the transition is stale.
+	_, err = h.Transition(ctx, &testpb.ResumeProjectRequest{Name: name, Etag: created.GetEtag()}, "ACTIVE", "SUSPENDED")
+	assertCode(t, err, codes.Aborted)
+	if _, err := h.Transition(ctx, &testpb.ResumeProjectRequest{Name: name, Etag: suspended.GetEtag()}, "ACTIVE", "SUSPENDED"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUpdateAllowMissing(t *testing.T) {
+	ctx := context.Background()
+	h := newTestHandler[*testpb.Project](t)
+
+	tests := []struct {
+		name string
+		want codes.Code
+	}{
+		{"", codes.InvalidArgument},
+		{"organizations/-/projects/p1", codes.InvalidArgument},
+		{"organizations/acme/databases/p1", codes.InvalidArgument},
+		{"organizations/acme/projects/P1", codes.InvalidArgument},
+		{"organizations/acme/projects/p1/databases/d1", codes.InvalidArgument},
+		{"organizations/acme/projects/p1", codes.OK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &testpb.UpdateProjectRequest{Project: &testpb.Project{Name: tt.name, Uid: "provided"}, AllowMissing: true}
+			res, err := h.Update(ctx, req)
+			assertCode(t, err, tt.want)
+			if err != nil {
+				return
+			}
+
+			// The uid cleared as an output only field is generated.
+			if res.GetUid() == "" || res.GetUid() == "provided" {
+				t.Errorf("Update() created %v, want a generated uid", res)
+			}
+			if _, err := h.repo.Get(ctx, tt.name); err != nil {
+				t.Errorf("Get(%q) = %v after its creation by Update", tt.name, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,32 @@
+// Package testpb holds the Project resource and its Database children used by
+// the tests of the runtime packages. The project.proto file is generated by
+// aip-resource-proto-gen with:
+//
+//	aip-resource-proto-gen --package acme.v1 --service api.acme.com \
+//	  --resource-parent 'organizations/{organization}' \
+//	  --resource-with-uid --resource-with-etag --resource-with-labels \
+//	  --resource-children databases \
+//	  --state ACTIVE,SUSPENDED --state-transitions Suspend=SUSPENDED,Resume=ACTIVE \
+//	  --enum Tier=BASIC,PREMIUM \
+//	  --field region=100:string:IMMUTABLE --field tier=101:Tier \
+//	  --field replicas=102:int32 --field quota=103:uint64 --field spec=104:Spec \
+//	  --field Spec.disk_size=1:int64 --field Spec.zone=2:string:REQUIRED \
+//	  --field 'tags=105:repeated string:UNORDERED_LIST' \
+//	  --with-validate-only --with-request-id --with-list-skip \
+//	  --with-list-total-size --with-search --with-read-mask \
+//	  Project > project.proto
+//
+// the database.proto file, with the resource views the Project lacks, with:
+//
+//	aip-resource-proto-gen --package acme.v1 --service api.acme.com \
+//	  --resource-parent 'organizations/{organization}/projects/{project}' \
+//	  --resource-with-etag --with-view --with-validate-only \
+//	  Database > database.proto
+//
+// and their Go code by protoc-gen-go with:
+//
+//	protoc --go_out=. --go_opt=paths=source_relative \
+//	  --go_opt=Mproject.proto=github.com/fsaintjacques/aip-resource-proto-gen/pkg/internal/testpb \
+//	  --go_opt=Mdatabase.proto=github.com/fsaintjacques/aip-resource-proto-gen/pkg/internal/testpb \
+//	  project.proto database.proto
+package testpb
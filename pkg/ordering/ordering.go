@@ -0,0 +1,54 @@
+// Package ordering parses the order_by of List methods as described in
+// AIP-132.
+package ordering
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Field is a single field of an order_by, e.g. `create_time desc`.
+type Field struct {
+	// Path of the field, e.g. [spec size] for `spec.size`.
+	Path []string
+	// Desc is whether the results are sorted in descending order.
+	Desc bool
+}
+
+var fieldPath = regexp.MustCompile(`^[a-z_][a-z0-9_]*(\.[a-z_][a-z0-9_]*)*$`)
+
+// Parse parses an order_by made of comma separated fields, each optionally
+// followed by `desc` or `asc`, e.g. `display_name, create_time desc`.
+func Parse(orderBy string) ([]Field, error) {
+	if strings.TrimSpace(orderBy) == "" {
+		return nil, nil
+	}
+
+	var fields []Field
+	for _, part := range strings.Split(orderBy, ",") {
+		words := strings.Fields(part)
+		if len(words) == 0 || len(words) > 2 {
+			return nil, fmt.Errorf("order_by %q: invalid field %q", orderBy, strings.TrimSpace(part))
+		}
+
+		if !fieldPath.MatchString(words[0]) {
+			return nil, fmt.Errorf("order_by %q: invalid field %q", orderBy, words[0])
+		}
+
+		f := Field{Path: strings.Split(words[0], ".")}
+		if len(words) == 2 {
+			switch words[1] {
+			case "desc":
+				f.Desc = true
+			case "asc":
+			default:
+				return nil, fmt.Errorf("order_by %q: invalid direction %q, must be asc or desc", orderBy, words[1])
+			}
+		}
+
+		fields = append(fields, f)
+	}
+
+	return fields, nil
+}
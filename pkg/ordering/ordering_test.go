@@ -0,0 +1,53 @@
+package ordering
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		orderBy string
+		want    []Field
+	}{
+		{"", nil},
+		{"  ", nil},
+		{"display_name", []Field{{Path: []string{"display_name"}}}},
+		{"create_time desc", []Field{{Path: []string{"create_time"}, Desc: true}}},
+		{
+			"display_name asc, create_time desc,spec.size",
+			[]Field{
+				{Path: []string{"display_name"}},
+				{Path: []string{"create_time"}, Desc: true},
+				{Path: []string{"spec", "size"}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(tt.orderBy)
+		if err != nil {
+			t.Errorf("Parse(%q) failed: %v", tt.orderBy, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, want %v", tt.orderBy, got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, orderBy := range []string{
+		"display_name,",
+		",display_name",
+		"display_name descending",
+		"display_name desc asc",
+		"DisplayName",
+		"spec..size",
+		"labels.`env`",
+	} {
+		if fields, err := Parse(orderBy); err == nil {
+			t.Errorf("Parse(%q) = %v, want an error", orderBy, fields)
+		}
+	}
+}
@@ -0,0 +1,205 @@
+// Package requestid deduplicates retried requests carrying the same
+// request_id, as described in AIP-155.
+package requestid
+
+import (
+	"context"
+	"crypto/sha256"
+	"regexp"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// FieldName is the name of the request id field of requests.
+const FieldName = "request_id"
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+
+// Cache holds the responses of the requests carrying a request id for a
+// limited time, during which retries of a request get its original response.
+// It is safe for concurrent use.
+type Cache struct {
+	ttl        time.Duration
+	maxEntries int
+
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+type entry struct {
+	// digest of the request, to detect request ids reused for other requests.
+	digest [sha256.Size]byte
+	// done is closed once the original request completed.
+	done    chan struct{}
+	res     proto.Message
+	err     error
+	expires time.Time
+}
+
+// NewCache returns a cache keeping responses for ttl, and at most maxEntries
+// of them. The oldest responses are evicted first when the cache is full.
+func NewCache(ttl time.Duration, maxEntries int) *Cache {
+	return &Cache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    map[string]*entry{},
+	}
+}
+
+// UnaryServerInterceptor returns a gRPC interceptor deduplicating the requests
+// with a request_id field. The first request with a given id is handled while
+// its retries, including those received while it is being handled, return its
+// response. Failed requests are not cached so that they can be retried.
+//
+// Requests with an empty request_id are handled as-is, while those with a
+// request_id that is not a UUID or that was already used for a different
+// request are rejected with InvalidArgument.
+func (c *Cache) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		msg, ok := req.(proto.Message)
+		if !ok {
+			return handler(ctx, req)
+		}
+
+		id := Get(msg)
+		if id == "" {
+			return handler(ctx, req)
+		}
+
+		if !uuidPattern.MatchString(id) {
+			return nil, status.Errorf(codes.InvalidArgument, "request_id %q must be a UUID", id)
+		}
+
+		digest, err := digestOf(msg)
+		if err != nil {
+			return nil, status.Error(codes.Internal, err.Error())
+		}
+
+		return c.do(ctx, info.FullMethod+"/"+id, digest, func() (proto.Message, error) {
+			res, err := handler(ctx, req)
+			if err != nil {
+				return nil, err
+			}
+			out, _ := res.(proto.Message)
+			return out, nil
+		})
+	}
+}
+
+func (c *Cache) do(ctx context.Context, key string, digest [sha256.Size]byte, fn func() (proto.Message, error)) (any, error) {
+	for {
+		c.mu.Lock()
+		e, ok := c.entries[key]
+		if ok && e.isExpired() {
+			delete(c.entries, key)
+			ok = false
+		}
+
+		if !ok {
+			e = &entry{digest: digest, done: make(chan struct{})}
+			c.evict()
+			c.entries[key] = e
+			c.mu.Unlock()
+			return c.run(key, e, fn)
+		}
+		c.mu.Unlock()
+
+		if e.digest != digest {
+			return nil, status.Error(codes.InvalidArgument, "request_id was already used for a different request")
+		}
+
+		select {
+		case <-e.done:
+		case <-ctx.Done():
+			return nil, status.FromContextError(ctx.Err()).Err()
+		}
+
+		// The original request failed and was removed, handle the retry.
+		if e.err != nil {
+			continue
+		}
+		return proto.Clone(e.res), nil
+	}
+}
+
+func (c *Cache) run(key string, e *entry, fn func() (proto.Message, error)) (any, error) {
+	res, err := fn()
+
+	c.mu.Lock()
+	e.res, e.err = res, err
+	e.expires = time.Now().Add(c.ttl)
+	if err != nil {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(e.done)
+
+	if err != nil {
+		return nil, err
+	}
+	return proto.Clone(res), nil
+}
+
+// evict removes the expired entries and, if the cache is still full, the
+// completed entry expiring first. It must be called with the lock held.
+func (c *Cache) evict() {
+	if len(c.entries) < c.maxEntries {
+		return
+	}
+
+	var (
+		oldestKey string
+		oldest    *entry
+	)
+	for k, e := range c.entries {
+		if e.isExpired() {
+			delete(c.entries, k)
+			continue
+		}
+		if e.isDone() && (oldest == nil || e.expires.Before(oldest.expires)) {
+			oldestKey, oldest = k, e
+		}
+	}
+
+	if len(c.entries) >= c.maxEntries && oldest != nil {
+		delete(c.entries, oldestKey)
+	}
+}
+
+func (e *entry) isDone() bool {
+	select {
+	case <-e.done:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *entry) isExpired() bool {
+	return e.isDone() && time.Now().After(e.expires)
+}
+
+// Get returns the request id of the request, or an empty string if the
+// request has no request_id field.
+func Get(req proto.Message) string {
+	m := req.ProtoReflect()
+	fd := m.Descriptor().Fields().ByName(FieldName)
+	if fd == nil || fd.Kind() != protoreflect.StringKind || fd.IsList() {
+		return ""
+	}
+	return m.Get(fd).String()
+}
+
+func digestOf(req proto.Message) ([sha256.Size]byte, error) {
+	b, err := proto.MarshalOptions{Deterministic: true}.Marshal(req)
+	if err != nil {
+		return [sha256.Size]byte{}, err
+	}
+	return sha256.Sum256(b), nil
+}
@@ -0,0 +1,194 @@
+package requestid
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/internal/testpb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	id1 = "6f1b8c1e-1c5f-4b0e-9a55-7f2d5c0f8a01"
+	id2 = "6f1b8c1e-1c5f-4b0e-9a55-7f2d5c0f8a02"
+)
+
+// server counts the calls of a handler creating the projects of the requests.
+type server struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+	// release, if set, blocks the calls until it is closed.
+	release chan struct{}
+}
+
+func (s *server) handler(ctx context.Context, req any) (any, error) {
+	if s.release != nil {
+		<-s.release
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.calls++
+	if s.err != nil {
+		return nil, s.err
+	}
+	return &testpb.Project{Name: "organizations/acme/projects/" + req.(*testpb.CreateProjectRequest).GetProjectId(), Replicas: int32(s.calls)}, nil
+}
+
+func call(c *Cache, s *server, method string, req *testpb.CreateProjectRequest) (*testpb.Project, error) {
+	res, err := c.UnaryServerInterceptor()(context.Background(), req, &grpc.UnaryServerInfo{FullMethod: method}, s.handler)
+	if err != nil {
+		return nil, err
+	}
+	return res.(*testpb.Project), nil
+}
+
+func create(id, project string) *testpb.CreateProjectRequest {
+	return &testpb.CreateProjectRequest{Parent: "organizations/acme", ProjectId: project, RequestId: id}
+}
+
+func TestCache(t *testing.T) {
+	const method = "/acme.v1.ProjectService/CreateProject"
+
+	tests := []struct {
+		name      string
+		requests  []*testpb.CreateProjectRequest
+		wantCalls int
+		wantCode  codes.Code
+	}{
+		{"retry", []*testpb.CreateProjectRequest{create(id1, "p1"), create(id1, "p1")}, 1, codes.OK},
+		{"no request id", []*testpb.CreateProjectRequest{create("", "p1"), create("", "p1")}, 2, codes.OK},
+		{"distinct request ids", []*testpb.CreateProjectRequest{create(id1, "p1"), create(id2, "p1")}, 2, codes.OK},
+		{"reused request id", []*testpb.CreateProjectRequest{create(id1, "p1"), create(id1, "p2")}, 1, codes.InvalidArgument},
+		{"invalid request id", []*testpb.CreateProjectRequest{create("not-a-uuid", "p1")}, 0, codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, s := NewCache(time.Minute, 10), &server{}
+
+			var (
+				first *testpb.Project
+				err   error
+			)
+			for _, req := range tt.requests {
+				var res *testpb.Project
+				res, err = call(c, s, method, req)
+				if first == nil {
+					first = res
+				} else if err == nil && req.GetRequestId() == tt.requests[0].GetRequestId() && req.GetRequestId() != "" && !proto.Equal(res, first) {
+					t.Errorf("retry returned %v, want %v", res, first)
+				}
+			}
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("last call = %v, want %s", err, tt.wantCode)
+			}
+			if s.calls != tt.wantCalls {
+				t.Errorf("handler called %d times, want %d", s.calls, tt.wantCalls)
+			}
+		})
+	}
+}
+
+func TestCacheScope(t *testing.T) {
+	c, s := NewCache(time.Minute, 10), &server{}
+
+	// The request ids are scoped to their method.
+	if _, err := call(c, s, "/acme.v1.ProjectService/CreateProject", create(id1, "p1")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := call(c, s, "/acme.v1.OtherService/CreateProject", create(id1, "p1")); err != nil {
+		t.Fatal(err)
+	}
+	if s.calls != 2 {
+		t.Errorf("handler called %d times, want 2", s.calls)
+	}
+}
+
+func TestCacheFailure(t *testing.T) {
+	c, s := NewCache(time.Minute, 10), &server{err: status.Error(codes.Unavailable, "unavailable")}
+
+	if _, err := call(c, s, "/m", create(id1, "p1")); status.Code(err) != codes.Unavailable {
+		t.Fatalf("call() = %v, want Unavailable", err)
+	}
+
+	// Failed requests are not cached.
+	s.err = nil
+	if _, err := call(c, s, "/m", create(id1, "p1")); err != nil {
+		t.Fatal(err)
+	}
+	if s.calls != 2 {
+		t.Errorf("handler called %d times, want 2", s.calls)
+	}
+}
+
+func TestCacheConcurrentRetry(t *testing.T) {
+	c, s := NewCache(time.Minute, 10), &server{release: make(chan struct{})}
+
+	results := make(chan *testpb.Project, 2)
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			res, err := call(c, s, "/m", create(id1, "p1"))
+			results <- res
+			errs <- err
+		}()
+	}
+
+	// Let both calls reach the cache before completing the first one.
+	time.Sleep(10 * time.Millisecond)
+	close(s.release)
+
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if a, b := <-results, <-results; !proto.Equal(a, b) {
+		t.Errorf("concurrent retries returned %v and %v", a, b)
+	}
+	if s.calls != 1 {
+		t.Errorf("handler called %d times, want 1", s.calls)
+	}
+}
+
+func TestCacheEviction(t *testing.T) {
+	// Expired responses are not returned.
+	c, s := NewCache(time.Millisecond, 10), &server{}
+	if _, err := call(c, s, "/m", create(id1, "p1")); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(5 * time.Millisecond)
+	if _, err := call(c, s, "/m", create(id1, "p1")); err != nil {
+		t.Fatal(err)
+	}
+	if s.calls != 2 {
+		t.Errorf("handler called %d times after expiration, want 2", s.calls)
+	}
+
+	// The oldest responses are evicted from a full cache.
+	c, s = NewCache(time.Minute, 1), &server{}
+	for _, id := range []string{id1, id2, id1} {
+		if _, err := call(c, s, "/m", create(id, "p1")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if s.calls != 3 {
+		t.Errorf("handler called %d times after eviction, want 3", s.calls)
+	}
+}
+
+func TestGet(t *testing.T) {
+	if got := Get(create(id1, "p1")); got != id1 {
+		t.Errorf("Get() = %q, want %q", got, id1)
+	}
+	if got := Get(&testpb.GetProjectRequest{Name: "organizations/acme/projects/p1"}); got != "" {
+		t.Errorf("Get() = %q for a request without request_id", got)
+	}
+}
@@ -0,0 +1,101 @@
+// Package resourcename provides the helpers shared by the generated resource
+// name parsers, and handles the names with wildcards for reads across
+// collections and the names of resource revisions.
+package resourcename
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// maxIDLength is the maximum length of a resource ID as recommended by AIP-122.
+const maxIDLength = 63
+
+// RevisionSeparator separates the resource name and the revision ID in the
+// name of a resource revision, e.g. `projects/p1@c7cfa2a8` (AIP-162).
+const RevisionSeparator = "@"
+
+// Wildcard is the resource ID standing for any ID in the parent of a List
+// request reading across collections, e.g. `organizations/-` (AIP-159).
+const Wildcard = "-"
+
+// ValidateID checks that id is a valid resource ID segment, e.g. the
+// `{project}` part of `projects/{project}`. Following AIP-122 a valid ID is
+// made of lowercase letters, digits and hyphens, is at most 63 characters long,
+// and neither starts nor ends with a hyphen.
+func ValidateID(id string) error {
+	if id == "" {
+		return errors.New("resource id must not be empty")
+	}
+
+	if len(id) > maxIDLength {
+		return fmt.Errorf("resource id %q must be at most %d characters", id, maxIDLength)
+	}
+
+	if id[0] == '-' || id[len(id)-1] == '-' {
+		return fmt.Errorf("resource id %q must not start or end with a hyphen", id)
+	}
+
+	for _, r := range id {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-') {
+			return fmt.Errorf("resource id %q contains invalid character %q", id, r)
+		}
+	}
+
+	return nil
+}
+
+// HasWildcard returns whether any ID segment of name is the Wildcard.
+func HasWildcard(name string) bool {
+	for _, segment := range strings.Split(name, "/") {
+		if segment == Wildcard {
+			return true
+		}
+	}
+	return false
+}
+
+// Parse parses name according to pattern, e.g.
+// `organizations/{organization}/projects/{project}`, and returns the values of
+// its variables by name. Values may be the Wildcard, letting List
+// implementations fan out across the parents, but are otherwise not validated.
+func Parse(pattern, name string) (map[string]string, error) {
+	patternSegments := strings.Split(pattern, "/")
+	segments := strings.Split(name, "/")
+	if len(segments) != len(patternSegments) {
+		return nil, fmt.Errorf("name %q does not match pattern %q", name, pattern)
+	}
+
+	values := map[string]string{}
+	for i, p := range patternSegments {
+		if !strings.HasPrefix(p, "{") || !strings.HasSuffix(p, "}") {
+			if segments[i] != p {
+				return nil, fmt.Errorf("name %q does not match pattern %q", name, pattern)
+			}
+			continue
+		}
+
+		if segments[i] == "" {
+			return nil, fmt.Errorf("name %q has an empty %s", name, p)
+		}
+		values[p[1:len(p)-1]] = segments[i]
+	}
+
+	return values, nil
+}
+
+// SplitRevision splits the name of a resource revision into the resource name
+// and the revision ID, which is empty if name has none.
+func SplitRevision(name string) (string, string) {
+	if i := strings.LastIndex(name, RevisionSeparator); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+	return name, ""
+}
+
+// RevisionName returns the name of the revision of the resource name with the
+// given revision ID.
+func RevisionName(name, revisionID string) string {
+	return name + RevisionSeparator + revisionID
+}
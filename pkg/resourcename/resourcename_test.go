@@ -0,0 +1,91 @@
+package resourcename
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestValidateID(t *testing.T) {
+	tests := []struct {
+		id    string
+		valid bool
+	}{
+		{"p1", true},
+		{"my-project-1", true},
+		{"", false},
+		{"-", false},
+		{"-p1", false},
+		{"p1-", false},
+		{"P1", false},
+		{"p_1", false},
+		{"a123456789012345678901234567890123456789012345678901234567890bc", true},
+		{"a123456789012345678901234567890123456789012345678901234567890bcd", false},
+	}
+
+	for _, tt := range tests {
+		if err := ValidateID(tt.id); (err == nil) != tt.valid {
+			t.Errorf("ValidateID(%q) = %v, want valid %v", tt.id, err, tt.valid)
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	const pattern = "organizations/{organization}/projects/{project}"
+
+	tests := []struct {
+		name string
+		want map[string]string
+	}{
+		{"organizations/acme/projects/p1", map[string]string{"organization": "acme", "project": "p1"}},
+		{"organizations/-/projects/p1", map[string]string{"organization": "-", "project": "p1"}},
+		{"organizations/-/projects/-", map[string]string{"organization": "-", "project": "-"}},
+		{"organizations/acme", nil},
+		{"organizations/acme/projects/p1/databases/d1", nil},
+		{"folders/acme/projects/p1", nil},
+		{"organizations//projects/p1", nil},
+	}
+
+	for _, tt := range tests {
+		got, err := Parse(pattern, tt.name)
+		if (err == nil) != (tt.want != nil) || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Parse(%q) = %v, %v, want %v", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestHasWildcard(t *testing.T) {
+	tests := []struct {
+		name string
+		want bool
+	}{
+		{"organizations/-", true},
+		{"organizations/-/projects/p1", true},
+		{"organizations/acme/projects/p1", false},
+		{"organizations/a-b", false},
+	}
+
+	for _, tt := range tests {
+		if got := HasWildcard(tt.name); got != tt.want {
+			t.Errorf("HasWildcard(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSplitRevision(t *testing.T) {
+	tests := []struct {
+		name, resource, revision string
+	}{
+		{"projects/p1@c7cfa2a8", "projects/p1", "c7cfa2a8"},
+		{"projects/p1", "projects/p1", ""},
+	}
+
+	for _, tt := range tests {
+		resource, revision := SplitRevision(tt.name)
+		if resource != tt.resource || revision != tt.revision {
+			t.Errorf("SplitRevision(%q) = %q, %q, want %q, %q", tt.name, resource, revision, tt.resource, tt.revision)
+		}
+		if revision != "" && RevisionName(resource, revision) != tt.name {
+			t.Errorf("RevisionName(%q, %q) = %q, want %q", resource, revision, RevisionName(resource, revision), tt.name)
+		}
+	}
+}
@@ -0,0 +1,219 @@
+package sqlstore
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// value returns the value of the column for the resource m.
+func (c Column) value(m protoreflect.Message) (any, error) {
+	if c.Field == nil {
+		return parentOf(m.Get(m.Descriptor().Fields().ByName("name")).String()), nil
+	}
+
+	fd := c.Field
+	if fd.HasPresence() && !m.Has(fd) {
+		return nil, nil
+	}
+
+	v := m.Get(fd)
+	switch c.kind {
+	case kindText:
+		return v.String(), nil
+	case kindBool:
+		return v.Bool(), nil
+	case kindInteger, kindBigint:
+		switch fd.Kind() {
+		case protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+			// Unsigned 64-bit values are stored in signed BIGINT columns.
+			if v.Uint() > math.MaxInt64 {
+				return nil, fmt.Errorf("column %s: value %d out of range", c.Name, v.Uint())
+			}
+			return int64(v.Uint()), nil
+		default:
+			return v.Int(), nil
+		}
+	case kindReal, kindDouble:
+		return v.Float(), nil
+	case kindBytes:
+		return v.Bytes(), nil
+	case kindEnum:
+		if ev := fd.Enum().Values().ByNumber(v.Enum()); ev != nil {
+			return string(ev.Name()), nil
+		}
+		return nil, fmt.Errorf("column %s: unknown enum value %d", c.Name, v.Enum())
+	case kindTimestamp:
+		return timestampValue(v.Message()), nil
+	case kindJSON:
+		if (fd.IsList() || fd.IsMap()) && !m.Has(fd) {
+			return nil, nil
+		}
+		b, err := marshalField(m, fd)
+		if err != nil {
+			return nil, fmt.Errorf("column %s: %v", c.Name, err)
+		}
+		return string(b), nil
+	}
+
+	return nil, fmt.Errorf("column %s: unsupported kind", c.Name)
+}
+
+// dest returns a pointer suitable for sql.Rows.Scan of the column.
+func (c Column) dest() any {
+	switch c.kind {
+	case kindBool:
+		return new(sql.NullBool)
+	case kindInteger, kindBigint:
+		return new(sql.NullInt64)
+	case kindReal, kindDouble:
+		return new(sql.NullFloat64)
+	case kindBytes, kindJSON:
+		return new([]byte)
+	case kindTimestamp:
+		return new(sql.NullTime)
+	default:
+		return new(sql.NullString)
+	}
+}
+
+// set sets the field of the column in m from a dest filled by sql.Rows.Scan.
+func (c Column) set(m protoreflect.Message, dest any) error {
+	fd := c.Field
+	if fd == nil {
+		return nil
+	}
+
+	switch d := dest.(type) {
+	case *sql.NullString:
+		if !d.Valid {
+			return nil
+		}
+		if c.kind == kindEnum {
+			ev := fd.Enum().Values().ByName(protoreflect.Name(d.String))
+			if ev == nil {
+				return fmt.Errorf("column %s: unknown enum value %q", c.Name, d.String)
+			}
+			m.Set(fd, protoreflect.ValueOfEnum(ev.Number()))
+			return nil
+		}
+		m.Set(fd, protoreflect.ValueOfString(d.String))
+	case *sql.NullBool:
+		if d.Valid {
+			m.Set(fd, protoreflect.ValueOfBool(d.Bool))
+		}
+	case *sql.NullInt64:
+		if d.Valid {
+			m.Set(fd, intValue(fd, d.Int64))
+		}
+	case *sql.NullFloat64:
+		if !d.Valid {
+			return nil
+		}
+		if fd.Kind() == protoreflect.FloatKind {
+			m.Set(fd, protoreflect.ValueOfFloat32(float32(d.Float64)))
+		} else {
+			m.Set(fd, protoreflect.ValueOfFloat64(d.Float64))
+		}
+	case *sql.NullTime:
+		if d.Valid {
+			ts := m.NewField(fd).Message()
+			setTimestamp(ts, d.Time)
+			m.Set(fd, protoreflect.ValueOfMessage(ts))
+		}
+	case *[]byte:
+		if *d == nil {
+			return nil
+		}
+		if c.kind == kindBytes {
+			m.Set(fd, protoreflect.ValueOfBytes(*d))
+			return nil
+		}
+		if err := unmarshalField(m, fd, *d); err != nil {
+			return fmt.Errorf("column %s: %v", c.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func intValue(fd protoreflect.FieldDescriptor, n int64) protoreflect.Value {
+	switch fd.Kind() {
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return protoreflect.ValueOfInt32(int32(n))
+	case protoreflect.Uint32Kind, protoreflect.Fixed32Kind:
+		return protoreflect.ValueOfUint32(uint32(n))
+	case protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return protoreflect.ValueOfUint64(uint64(n))
+	default:
+		return protoreflect.ValueOfInt64(n)
+	}
+}
+
+// timestampValue converts a google.protobuf.Timestamp, which may be a dynamic
+// message, to a time.Time.
+func timestampValue(ts protoreflect.Message) time.Time {
+	fields := ts.Descriptor().Fields()
+	seconds := ts.Get(fields.ByName("seconds")).Int()
+	nanos := ts.Get(fields.ByName("nanos")).Int()
+	return time.Unix(seconds, nanos).UTC()
+}
+
+func setTimestamp(ts protoreflect.Message, t time.Time) {
+	fields := ts.Descriptor().Fields()
+	ts.Set(fields.ByName("seconds"), protoreflect.ValueOfInt64(t.Unix()))
+	ts.Set(fields.ByName("nanos"), protoreflect.ValueOfInt32(int32(t.Nanosecond())))
+}
+
+// marshalField returns the JSON encoding of the field fd of m, as found in the
+// JSON encoding of m.
+func marshalField(m protoreflect.Message, fd protoreflect.FieldDescriptor) ([]byte, error) {
+	partial := m.Type().New()
+	partial.Set(fd, m.Get(fd))
+
+	b, err := protojson.MarshalOptions{UseProtoNames: true}.Marshal(partial.Interface())
+	if err != nil {
+		return nil, err
+	}
+
+	var obj map[string]json.RawMessage
+	if err := json.Unmarshal(b, &obj); err != nil {
+		return nil, err
+	}
+
+	return obj[string(fd.Name())], nil
+}
+
+// unmarshalField sets the field fd of m from its JSON encoding.
+func unmarshalField(m protoreflect.Message, fd protoreflect.FieldDescriptor, raw []byte) error {
+	b, err := json.Marshal(map[string]json.RawMessage{string(fd.Name()): raw})
+	if err != nil {
+		return err
+	}
+
+	partial := m.Type().New()
+	if err := protojson.Unmarshal(b, partial.Interface()); err != nil {
+		return err
+	}
+
+	if partial.Has(fd) {
+		m.Set(fd, partial.Get(fd))
+	}
+	return nil
+}
+
+// parentOf returns the name of the parent of the resource, which is the
+// resource name stripped of its last collection and id segments.
+func parentOf(name string) string {
+	segments := strings.Split(name, "/")
+	if len(segments) < 2 {
+		return ""
+	}
+	return strings.Join(segments[:len(segments)-2], "/")
+}
@@ -0,0 +1,97 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Dialect abstracts the differences between the supported SQL databases.
+type Dialect interface {
+	// Name of the dialect, as accepted by DialectByName.
+	Name() string
+
+	placeholder(n int) string
+	columnType(kind columnKind) string
+	// jsonText returns the expression extracting the text value of the key of
+	// a JSON object column, binding its arguments with bind.
+	jsonText(column, key string, bind func(any) string) string
+}
+
+var (
+	// Postgres is the dialect of PostgreSQL.
+	Postgres Dialect = postgres{}
+	// SQLite is the dialect of SQLite, mostly useful as a local stand-in.
+	SQLite Dialect = sqlite{}
+)
+
+// DialectByName returns the dialect with the given name.
+func DialectByName(name string) (Dialect, error) {
+	for _, d := range []Dialect{Postgres, SQLite} {
+		if d.Name() == name {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown SQL dialect %q", name)
+}
+
+type postgres struct{}
+
+func (postgres) Name() string { return "postgres" }
+
+func (postgres) placeholder(n int) string { return "$" + strconv.Itoa(n) }
+
+func (postgres) columnType(kind columnKind) string {
+	switch kind {
+	case kindBool:
+		return "BOOLEAN"
+	case kindInteger:
+		return "INTEGER"
+	case kindBigint:
+		return "BIGINT"
+	case kindReal:
+		return "REAL"
+	case kindDouble:
+		return "DOUBLE PRECISION"
+	case kindBytes:
+		return "BYTEA"
+	case kindTimestamp:
+		return "TIMESTAMPTZ"
+	case kindJSON:
+		return "JSONB"
+	default:
+		return "TEXT"
+	}
+}
+
+func (postgres) jsonText(column, key string, bind func(any) string) string {
+	return column + " ->> " + bind(key) + "::text"
+}
+
+type sqlite struct{}
+
+func (sqlite) Name() string { return "sqlite" }
+
+func (sqlite) placeholder(int) string { return "?" }
+
+func (sqlite) columnType(kind columnKind) string {
+	switch kind {
+	case kindBool:
+		return "BOOLEAN"
+	case kindInteger, kindBigint:
+		return "INTEGER"
+	case kindReal, kindDouble:
+		return "REAL"
+	case kindBytes:
+		return "BLOB"
+	case kindTimestamp:
+		return "TIMESTAMP"
+	default:
+		return "TEXT"
+	}
+}
+
+func (sqlite) jsonText(column, key string, bind func(any) string) string {
+	path := `$."` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(key) + `"`
+	return "json_extract(" + column + ", " + bind(path) + ")"
+}
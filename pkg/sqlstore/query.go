@@ -0,0 +1,273 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/filtering"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/ordering"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// CompileFilter compiles a parsed AIP-160 filter into a parameterized SQL
+// condition on the columns of the table. The arguments of the condition are
+// appended to args, which holds the arguments of the placeholders preceding
+// the condition in the query.
+//
+// Restrictions are supported on the scalar, enum and timestamp columns, on the
+// entries of string maps (e.g. `annotations.owner = "me"`) and for field
+// presence (e.g. `expire_time:*`). Quoted strings starting or ending with `*`
+// compare with a wildcard, e.g. `display_name = "acme*"`.
+func (t *Table) CompileFilter(d Dialect, expr filtering.Expr, args []any) (string, []any, error) {
+	c := &compiler{table: t, dialect: d, args: args}
+
+	cond, err := c.compile(expr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return cond, c.args, nil
+}
+
+// CompileOrderBy compiles a parsed AIP-132 order_by into a SQL ORDER BY list.
+// The resource name is always the last ordering term so that the order is
+// total, as required for stable pagination.
+func (t *Table) CompileOrderBy(fields []ordering.Field) (string, error) {
+	var (
+		terms   []string
+		hasName bool
+	)
+	for _, f := range fields {
+		if len(f.Path) > 1 {
+			return "", fmt.Errorf("ordering by nested field %q is not supported", strings.Join(f.Path, "."))
+		}
+
+		col, ok := t.fieldColumn(f.Path[0])
+		if !ok {
+			return "", fmt.Errorf("unknown field %q", f.Path[0])
+		}
+
+		switch col.kind {
+		case kindJSON, kindBytes:
+			return "", fmt.Errorf("ordering by field %q is not supported", col.Name)
+		}
+
+		term := col.Name
+		if f.Desc {
+			term += " DESC"
+		}
+		terms = append(terms, term)
+		hasName = hasName || col.Name == "name"
+	}
+
+	if !hasName {
+		terms = append(terms, "name")
+	}
+
+	return strings.Join(terms, ", "), nil
+}
+
+// fieldColumn returns the column storing the field with the given name.
+func (t *Table) fieldColumn(name string) (Column, bool) {
+	c, ok := t.Column(name)
+	return c, ok && c.Field != nil
+}
+
+type compiler struct {
+	table   *Table
+	dialect Dialect
+	args    []any
+}
+
+func (c *compiler) bind(v any) string {
+	c.args = append(c.args, v)
+	return c.dialect.placeholder(len(c.args))
+}
+
+func (c *compiler) compile(expr filtering.Expr) (string, error) {
+	switch e := expr.(type) {
+	case filtering.And:
+		return c.join(e.Exprs, " AND ")
+	case filtering.Or:
+		return c.join(e.Exprs, " OR ")
+	case filtering.Not:
+		cond, err := c.compile(e.Expr)
+		if err != nil {
+			return "", err
+		}
+		return "NOT " + cond, nil
+	case filtering.Restriction:
+		cond, err := c.restriction(e)
+		if err != nil {
+			return "", err
+		}
+		return "(" + cond + ")", nil
+	default:
+		return "", fmt.Errorf("unsupported filter expression %T", expr)
+	}
+}
+
+func (c *compiler) join(exprs []filtering.Expr, op string) (string, error) {
+	conds := make([]string, len(exprs))
+	for i, e := range exprs {
+		cond, err := c.compile(e)
+		if err != nil {
+			return "", err
+		}
+		conds[i] = cond
+	}
+	return "(" + strings.Join(conds, op) + ")", nil
+}
+
+func (c *compiler) restriction(r filtering.Restriction) (string, error) {
+	field := strings.Join(r.Path, ".")
+
+	col, ok := c.table.fieldColumn(r.Path[0])
+	if !ok {
+		return "", fmt.Errorf("unknown field %q", r.Path[0])
+	}
+
+	if len(r.Path) > 1 {
+		return c.mapEntry(col, r)
+	}
+
+	if r.Comparator == filtering.Has {
+		if r.Arg.Value == "*" && !r.Arg.Quoted {
+			return col.Name + " IS NOT NULL", nil
+		}
+		if col.Field.IsMap() {
+			return c.mapEntry(col, filtering.Restriction{
+				Path:       []string{col.Name, r.Arg.Value},
+				Comparator: filtering.Has,
+				Arg:        filtering.Arg{Value: "*"},
+			})
+		}
+		return "", fmt.Errorf("operator %q is not supported on field %q", r.Comparator, field)
+	}
+
+	switch col.kind {
+	case kindJSON, kindBytes:
+		return "", fmt.Errorf("filtering on field %q is not supported", field)
+	case kindBool, kindEnum:
+		if r.Comparator != filtering.Equals && r.Comparator != filtering.NotEquals {
+			return "", fmt.Errorf("operator %q is not supported on field %q", r.Comparator, field)
+		}
+	}
+
+	if col.kind == kindText && r.Arg.Quoted && isWildcard(r.Arg.Value) {
+		return c.like(col.Name, r)
+	}
+
+	v, err := argValue(col, r.Arg)
+	if err != nil {
+		return "", fmt.Errorf("field %q: %v", field, err)
+	}
+
+	return col.Name + " " + sqlComparator(r.Comparator) + " " + c.bind(v), nil
+}
+
+// mapEntry compiles a restriction on an entry of a map with string keys and
+// values, e.g. `annotations.owner = "me"` or `annotations.owner:*`.
+func (c *compiler) mapEntry(col Column, r filtering.Restriction) (string, error) {
+	field := strings.Join(r.Path, ".")
+
+	fd := col.Field
+	if !fd.IsMap() || len(r.Path) != 2 ||
+		fd.MapKey().Kind() != protoreflect.StringKind || fd.MapValue().Kind() != protoreflect.StringKind {
+		return "", fmt.Errorf("filtering on nested field %q is not supported", field)
+	}
+
+	entry := c.dialect.jsonText(col.Name, r.Path[1], c.bind)
+
+	switch r.Comparator {
+	case filtering.Has:
+		if r.Arg.Value != "*" || r.Arg.Quoted {
+			return "", fmt.Errorf("operator %q on field %q only supports the * value", r.Comparator, field)
+		}
+		return entry + " IS NOT NULL", nil
+	case filtering.Equals, filtering.NotEquals:
+		if r.Arg.Quoted && isWildcard(r.Arg.Value) {
+			return c.like(entry, r)
+		}
+		return entry + " " + sqlComparator(r.Comparator) + " " + c.bind(r.Arg.Value), nil
+	default:
+		return "", fmt.Errorf("operator %q is not supported on field %q", r.Comparator, field)
+	}
+}
+
+// like compiles a string comparison with a leading or trailing `*` wildcard.
+func (c *compiler) like(expr string, r filtering.Restriction) (string, error) {
+	op := "LIKE"
+	switch r.Comparator {
+	case filtering.Equals:
+	case filtering.NotEquals:
+		op = "NOT LIKE"
+	default:
+		return "", fmt.Errorf("operator %q is not supported with wildcards", r.Comparator)
+	}
+
+	v := r.Arg.Value
+	prefix, suffix := strings.HasPrefix(v, "*"), strings.HasSuffix(v, "*")
+	v = strings.TrimSuffix(strings.TrimPrefix(v, "*"), "*")
+	v = likeEscaper.Replace(v)
+	if prefix {
+		v = "%" + v
+	}
+	if suffix {
+		v += "%"
+	}
+
+	return expr + " " + op + " " + c.bind(v) + ` ESCAPE '\'`, nil
+}
+
+func isWildcard(s string) bool {
+	return len(s) > 1 && (strings.HasPrefix(s, "*") || strings.HasSuffix(s, "*"))
+}
+
+func sqlComparator(c filtering.Comparator) string {
+	if c == filtering.NotEquals {
+		return "<>"
+	}
+	return string(c)
+}
+
+// argValue converts the value of a restriction to the type of the column.
+func argValue(col Column, arg filtering.Arg) (any, error) {
+	switch col.kind {
+	case kindText:
+		return arg.Value, nil
+	case kindEnum:
+		if col.Field.Enum().Values().ByName(protoreflect.Name(arg.Value)) == nil {
+			return nil, fmt.Errorf("unknown enum value %q", arg.Value)
+		}
+		return arg.Value, nil
+	case kindBool:
+		b, err := strconv.ParseBool(arg.Value)
+		if err != nil || arg.Quoted {
+			return nil, fmt.Errorf("invalid boolean %q", arg.Value)
+		}
+		return b, nil
+	case kindInteger, kindBigint:
+		n, err := strconv.ParseInt(arg.Value, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid integer %q", arg.Value)
+		}
+		return n, nil
+	case kindReal, kindDouble:
+		f, err := strconv.ParseFloat(arg.Value, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", arg.Value)
+		}
+		return f, nil
+	case kindTimestamp:
+		t, err := time.Parse(time.RFC3339Nano, arg.Value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid RFC 3339 timestamp %q", arg.Value)
+		}
+		return t.UTC(), nil
+	default:
+		return nil, fmt.Errorf("unsupported value %q", arg.Value)
+	}
+}
@@ -0,0 +1,121 @@
+package sqlstore
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/filtering"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/internal/testpb"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/ordering"
+)
+
+func newTestTable(t *testing.T) *Table {
+	t.Helper()
+
+	table, err := NewTable((&testpb.Project{}).ProtoReflect().Descriptor())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return table
+}
+
+func TestCompileFilter(t *testing.T) {
+	table := newTestTable(t)
+
+	tests := []struct {
+		filter   string
+		dialect  Dialect
+		wantCond string
+		wantArgs []any
+	}{
+		{`display_name = "acme"`, Postgres, `(display_name = $2)`, []any{"acme"}},
+		{`display_name != "acme"`, SQLite, `(display_name <> ?)`, []any{"acme"}},
+		{`display_name = "acme*"`, Postgres, `(display_name LIKE $2 ESCAPE '\')`, []any{"acme%"}},
+		{`display_name != "*50%"`, Postgres, `(display_name NOT LIKE $2 ESCAPE '\')`, []any{`%50\%`}},
+		{`replicas >= 3 AND quota < 10`, Postgres, `((replicas >= $2) AND (quota < $3))`, []any{int64(3), int64(10)}},
+		{`tier = PREMIUM OR NOT state = ACTIVE`, Postgres, `((tier = $2) OR NOT (state = $3))`, []any{"PREMIUM", "ACTIVE"}},
+		{`create_time > "2024-01-01T00:00:00+01:00"`, Postgres, `(create_time > $2)`, []any{time.Date(2023, 12, 31, 23, 0, 0, 0, time.UTC)}},
+		{`spec:*`, Postgres, `(spec IS NOT NULL)`, nil},
+		{`labels.env = "prod"`, Postgres, `(labels ->> $2::text = $3)`, []any{"env", "prod"}},
+		{`labels.env = "prod"`, SQLite, `(json_extract(labels, ?) = ?)`, []any{`$."env"`, "prod"}},
+		{`labels:env`, Postgres, `(labels ->> $2::text IS NOT NULL)`, []any{"env"}},
+		{"annotations.`acme.com/owner`:*", Postgres, `(annotations ->> $2::text IS NOT NULL)`, []any{"acme.com/owner"}},
+	}
+
+	for _, tt := range tests {
+		expr, err := filtering.Parse(tt.filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The condition follows the argument of the parent.
+		cond, args, err := table.CompileFilter(tt.dialect, expr, []any{"organizations/acme"})
+		if err != nil {
+			t.Errorf("CompileFilter(%q) failed: %v", tt.filter, err)
+			continue
+		}
+		wantArgs := append([]any{"organizations/acme"}, tt.wantArgs...)
+		if cond != tt.wantCond || fmt.Sprint(args) != fmt.Sprint(wantArgs) {
+			t.Errorf("CompileFilter(%q, %s) = %s %v, want %s %v", tt.filter, tt.dialect.Name(), cond, args, tt.wantCond, wantArgs)
+		}
+	}
+}
+
+func TestCompileFilterErrors(t *testing.T) {
+	table := newTestTable(t)
+
+	for _, filter := range []string{
+		`unknown = 1`,
+		`replicas = "many"`,
+		`replicas = 1.5`,
+		`tier = GOLD`,
+		`tier > BASIC`,
+		`create_time > "yesterday"`,
+		`spec = "x"`,
+		`spec.disk_size = 10`,
+		`tags = "a"`,
+		`display_name:"acme"`,
+		`labels.env > "prod"`,
+		`labels.env:"prod"`,
+		`replicas = "1*"`,
+		`display_name < "acme*"`,
+	} {
+		expr, err := filtering.Parse(filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cond, _, err := table.CompileFilter(Postgres, expr, nil); err == nil {
+			t.Errorf("CompileFilter(%q) = %s, want an error", filter, cond)
+		}
+	}
+}
+
+func TestCompileOrderBy(t *testing.T) {
+	table := newTestTable(t)
+
+	tests := []struct {
+		orderBy string
+		want    string
+		wantErr bool
+	}{
+		{"", "name", false},
+		{"display_name", "display_name, name", false},
+		{"create_time desc, replicas", "create_time DESC, replicas, name", false},
+		{"name desc", "name DESC", false},
+		{"tier, name, display_name", "tier, name, display_name", false},
+		{"unknown", "", true},
+		{"spec.disk_size", "", true},
+		{"labels", "", true},
+	}
+
+	for _, tt := range tests {
+		fields, err := ordering.Parse(tt.orderBy)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := table.CompileOrderBy(fields)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("CompileOrderBy(%q) = %q, %v, want %q", tt.orderBy, got, err, tt.want)
+		}
+	}
+}
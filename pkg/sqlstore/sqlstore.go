@@ -0,0 +1,505 @@
+// Package sqlstore persists AIP resources in SQL databases, with a table
+// shape mirroring the resource message.
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/etag"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/filtering"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/ordering"
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/resourcename"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+const (
+	// DefaultPageSize is the page size used by List when none is requested.
+	DefaultPageSize = 50
+	// MaxPageSize is the largest page size returned by List, larger requested
+	// page sizes are coerced to it as described in AIP-158.
+	MaxPageSize = 1000
+)
+
+// Repository stores the resources of type T, a generated resource message, in
+// a Table. Errors are returned as gRPC status errors so that they can be
+// propagated as-is by the service implementation.
+type Repository[T proto.Message] struct {
+	db      *sql.DB
+	dialect Dialect
+	table   *Table
+}
+
+// NewRepository returns a repository storing the resources of type T in db.
+func NewRepository[T proto.Message](db *sql.DB, dialect Dialect) (*Repository[T], error) {
+	var zero T
+	table, err := NewTable(zero.ProtoReflect().Descriptor())
+	if err != nil {
+		return nil, err
+	}
+
+	return &Repository[T]{db: db, dialect: dialect, table: table}, nil
+}
+
+// Table returns the table storing the resources.
+func (r *Repository[T]) Table() *Table {
+	return r.table
+}
+
+// Get returns the resource with the given name.
+func (r *Repository[T]) Get(ctx context.Context, name string) (T, error) {
+	var zero T
+
+	query := fmt.Sprintf("SELECT %s FROM %s WHERE name = %s",
+		strings.Join(r.table.columnNames(), ", "), r.table.Name, r.dialect.placeholder(1))
+
+	res, err := r.scan(r.conn(ctx).QueryRowContext(ctx, query, name))
+	if errors.Is(err, sql.ErrNoRows) {
+		return zero, status.Errorf(codes.NotFound, "resource %q not found", name)
+	}
+
+	return res, err
+}
+
+// ListOptions are the parameters of a List call, as found in List requests.
+type ListOptions struct {
+	// Parent restricts the listed resources to the children of this parent.
+	Parent string
+	// PageSize is the maximum number of resources to return.
+	PageSize int32
+	// PageToken is the next_page_token of a previous List call.
+	PageToken string
+	// Filter is an AIP-160 filter on the fields of the resource.
+	Filter string
+	// OrderBy is an AIP-132 order_by on the fields of the resource.
+	OrderBy string
+	// Skip is the number of resources to skip before the first page
+	// (AIP-158). The next pages, whose tokens already account for it, must
+	// be requested with the same skip.
+	Skip int32
+	// Query is a free-text query of Search methods. Each of its whitespace
+	// separated terms must be found, regardless of case, in a string field.
+	Query string
+}
+
+// List returns a page of the resources of the parent, along with the token
+// of the next page which is empty on the last page.
+func (r *Repository[T]) List(ctx context.Context, opts ListOptions) ([]T, string, error) {
+	if opts.PageSize < 0 {
+		return nil, "", status.Error(codes.InvalidArgument, "page_size must not be negative")
+	}
+
+	pageSize := int(opts.PageSize)
+	switch {
+	case pageSize == 0:
+		pageSize = DefaultPageSize
+	case pageSize > MaxPageSize:
+		pageSize = MaxPageSize
+	}
+
+	if opts.Skip < 0 {
+		return nil, "", status.Error(codes.InvalidArgument, "skip must not be negative")
+	}
+
+	checksum := listChecksum(opts)
+	offset, err := decodePageToken(opts.PageToken, checksum)
+	if err != nil {
+		return nil, "", err
+	}
+	if opts.PageToken == "" {
+		offset = int(opts.Skip)
+	}
+
+	where, args, err := r.where(opts)
+	if err != nil {
+		return nil, "", err
+	}
+
+	fields, err := ordering.Parse(opts.OrderBy)
+	if err != nil {
+		return nil, "", status.Errorf(codes.InvalidArgument, "invalid order_by: %v", err)
+	}
+	orderBy, err := r.table.CompileOrderBy(fields)
+	if err != nil {
+		return nil, "", status.Errorf(codes.InvalidArgument, "invalid order_by: %v", err)
+	}
+
+	var query strings.Builder
+	fmt.Fprintf(&query, "SELECT %s FROM %s%s", strings.Join(r.table.columnNames(), ", "), r.table.Name, where)
+	// Fetch one extra row to know whether there is a next page.
+	fmt.Fprintf(&query, " ORDER BY %s LIMIT %d OFFSET %d", orderBy, pageSize+1, offset)
+
+	rows, err := r.conn(ctx).QueryContext(ctx, query.String(), args...)
+	if err != nil {
+		return nil, "", internal(err)
+	}
+	defer rows.Close()
+
+	var resources []T
+	for rows.Next() {
+		res, err := r.scan(rows)
+		if err != nil {
+			return nil, "", err
+		}
+		resources = append(resources, res)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, "", internal(err)
+	}
+
+	var next string
+	if len(resources) > pageSize {
+		resources = resources[:pageSize]
+		next = encodePageToken(offset+pageSize, checksum)
+	}
+
+	return resources, next, nil
+}
+
+// Count returns the number of resources matching the parent, the filter and
+// the query of opts, regardless of pagination.
+func (r *Repository[T]) Count(ctx context.Context, opts ListOptions) (int64, error) {
+	where, args, err := r.where(opts)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	query := fmt.Sprintf("SELECT COUNT(*) FROM %s%s", r.table.Name, where)
+	if err := r.conn(ctx).QueryRowContext(ctx, query, args...).Scan(&n); err != nil {
+		return 0, internal(err)
+	}
+	return n, nil
+}
+
+// where returns the WHERE clause, if any, selecting the resources matching the
+// parent, the filter and the query of opts.
+func (r *Repository[T]) where(opts ListOptions) (string, []any, error) {
+	var (
+		conds []string
+		args  []any
+	)
+	switch {
+	case r.table.HasParent && resourcename.HasWildcard(opts.Parent):
+		args = append(args, parentLike(opts.Parent))
+		conds = append(conds, ParentColumn+" LIKE "+r.dialect.placeholder(len(args))+` ESCAPE '\'`)
+	case r.table.HasParent:
+		args = append(args, opts.Parent)
+		conds = append(conds, ParentColumn+" = "+r.dialect.placeholder(len(args)))
+	}
+
+	filter, err := filtering.Parse(opts.Filter)
+	if err != nil {
+		return "", nil, status.Errorf(codes.InvalidArgument, "invalid filter: %v", err)
+	}
+	if filter != nil {
+		var cond string
+		cond, args, err = r.table.CompileFilter(r.dialect, filter, args)
+		if err != nil {
+			return "", nil, status.Errorf(codes.InvalidArgument, "invalid filter: %v", err)
+		}
+		conds = append(conds, cond)
+	}
+
+	for _, term := range strings.Fields(opts.Query) {
+		term = "%" + likeEscaper.Replace(strings.ToLower(term)) + "%"
+
+		var matches []string
+		for _, c := range r.table.Columns {
+			if c.kind != kindText || c.Field == nil {
+				continue
+			}
+			args = append(args, term)
+			matches = append(matches, fmt.Sprintf(`LOWER(%s) LIKE %s ESCAPE '\'`, c.Name, r.dialect.placeholder(len(args))))
+		}
+		conds = append(conds, "("+strings.Join(matches, " OR ")+")")
+	}
+
+	if len(conds) == 0 {
+		return "", args, nil
+	}
+	return " WHERE " + strings.Join(conds, " AND "), args, nil
+}
+
+// Create stores a new resource, whose name must already be set, and returns
+// the stored resource. The create_time and update_time fields are set to the
+// current time and the etag field is computed when the resource has them.
+func (r *Repository[T]) Create(ctx context.Context, res T) (T, error) {
+	var zero T
+
+	res = proto.Clone(res).(T)
+	now := time.Now()
+	r.touch(res, "create_time", now)
+	r.touch(res, "update_time", now)
+	if err := etag.Set(res); err != nil {
+		return zero, internal(err)
+	}
+
+	values, err := r.values(res, r.table.Columns)
+	if err != nil {
+		return zero, err
+	}
+
+	// Conflicting inserts are ignored rather than failing with a unique
+	// violation, which is reported differently by every driver.
+	query := fmt.Sprintf("INSERT INTO %s (%s) VALUES (%s) ON CONFLICT (name) DO NOTHING",
+		r.table.Name, strings.Join(r.table.columnNames(), ", "), r.placeholders(len(values)))
+	result, err := r.conn(ctx).ExecContext(ctx, query, values...)
+	if err != nil {
+		return zero, internal(err)
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return zero, internal(err)
+	} else if n == 0 {
+		return zero, status.Errorf(codes.AlreadyExists, "resource %q already exists", values[0])
+	}
+
+	return res, nil
+}
+
+// Update replaces the stored resource with res and returns the stored
+// resource. The create_time column is never updated while the update_time field
+// is set to the current time when the resource has it. Partial updates are
+// implemented by applying the update mask onto the resource returned by Get,
+// see the fieldmask package.
+//
+// When the resource has an etag field, the etag of res is checked against the
+// stored etag as part of the update, failing with Aborted on mismatch, and a
+// new etag is computed. An empty etag skips the check.
+func (r *Repository[T]) Update(ctx context.Context, res T) (T, error) {
+	var zero T
+
+	res = proto.Clone(res).(T)
+	provided := etag.Get(res)
+	r.touch(res, "update_time", time.Now())
+	if err := etag.Set(res); err != nil {
+		return zero, internal(err)
+	}
+
+	var columns []Column
+	for _, c := range r.table.Columns[1:] {
+		if c.Name != "create_time" {
+			columns = append(columns, c)
+		}
+	}
+
+	values, err := r.values(res, columns)
+	if err != nil {
+		return zero, err
+	}
+
+	sets := make([]string, len(columns))
+	for i, c := range columns {
+		sets[i] = c.Name + " = " + r.dialect.placeholder(i+1)
+	}
+
+	name := nameOf(res)
+	values = append(values, name)
+	cond := "name = " + r.dialect.placeholder(len(values))
+	cond, values = r.etagCondition(cond, values, provided)
+	query := fmt.Sprintf("UPDATE %s SET %s WHERE %s", r.table.Name, strings.Join(sets, ", "), cond)
+
+	var stored T
+	err = r.InTx(ctx, func(ctx context.Context) error {
+		result, err := r.conn(ctx).ExecContext(ctx, query, values...)
+		if err != nil {
+			return internal(err)
+		}
+		if err := r.checkAffected(ctx, result, name, provided); err != nil {
+			return err
+		}
+
+		stored, err = r.Get(ctx, name)
+		return err
+	})
+	if err != nil {
+		return zero, err
+	}
+
+	return stored, nil
+}
+
+// Delete deletes the resource with the given name. When the resource has an
+// etag field and etag is not empty, the deletion fails with Aborted unless etag
+// matches the stored etag.
+func (r *Repository[T]) Delete(ctx context.Context, name, etag string) error {
+	cond, args := r.etagCondition("name = "+r.dialect.placeholder(1), []any{name}, etag)
+	query := fmt.Sprintf("DELETE FROM %s WHERE %s", r.table.Name, cond)
+
+	return r.InTx(ctx, func(ctx context.Context) error {
+		result, err := r.conn(ctx).ExecContext(ctx, query, args...)
+		if err != nil {
+			return internal(err)
+		}
+		return r.checkAffected(ctx, result, name, etag)
+	})
+}
+
+// etagCondition extends the condition of an update or a deletion to match
+// the provided etag, if any and if the resource has an etag column.
+func (r *Repository[T]) etagCondition(cond string, args []any, provided string) (string, []any) {
+	if _, ok := r.table.fieldColumn(etag.FieldName); !ok || provided == "" {
+		return cond, args
+	}
+
+	args = append(args, provided)
+	return cond + " AND " + etag.FieldName + " = " + r.dialect.placeholder(len(args)), args
+}
+
+// checkAffected checks that an update or a deletion affected the resource,
+// reporting whether the resource is missing or its etag did not match.
+func (r *Repository[T]) checkAffected(ctx context.Context, result sql.Result, name, provided string) error {
+	n, err := result.RowsAffected()
+	if err != nil {
+		return internal(err)
+	}
+	if n > 0 {
+		return nil
+	}
+
+	if _, err := r.Get(ctx, name); err != nil {
+		return err
+	}
+	return status.Errorf(codes.Aborted, "etag %q does not match the current etag of resource %q", provided, name)
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *Repository[T]) scan(row scanner) (T, error) {
+	var zero T
+
+	dests := make([]any, len(r.table.Columns))
+	for i, c := range r.table.Columns {
+		dests[i] = c.dest()
+	}
+
+	if err := row.Scan(dests...); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return zero, err
+		}
+		return zero, internal(err)
+	}
+
+	m := zero.ProtoReflect().Type().New()
+	for i, c := range r.table.Columns {
+		if err := c.set(m, dests[i]); err != nil {
+			return zero, internal(err)
+		}
+	}
+
+	return m.Interface().(T), nil
+}
+
+func (r *Repository[T]) values(res T, columns []Column) ([]any, error) {
+	m := res.ProtoReflect()
+
+	values := make([]any, len(columns))
+	for i, c := range columns {
+		v, err := c.value(m)
+		if err != nil {
+			return nil, status.Error(codes.InvalidArgument, err.Error())
+		}
+		values[i] = v
+	}
+
+	return values, nil
+}
+
+func (r *Repository[T]) placeholders(n int) string {
+	p := make([]string, n)
+	for i := range p {
+		p[i] = r.dialect.placeholder(i + 1)
+	}
+	return strings.Join(p, ", ")
+}
+
+// touch sets the timestamp field with the given name to t, if the resource
+// has such field.
+func (r *Repository[T]) touch(res T, field string, t time.Time) {
+	c, ok := r.table.Column(field)
+	if !ok || c.kind != kindTimestamp {
+		return
+	}
+
+	m := res.ProtoReflect()
+	ts := m.NewField(c.Field).Message()
+	setTimestamp(ts, t)
+	m.Set(c.Field, protoreflect.ValueOfMessage(ts))
+}
+
+func nameOf(res proto.Message) string {
+	m := res.ProtoReflect()
+	return m.Get(m.Descriptor().Fields().ByName("name")).String()
+}
+
+// likeEscaper escapes the special characters of LIKE patterns.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// parentLike returns the LIKE pattern matching the parents of a parent with
+// wildcard IDs, e.g. `organizations/%` for `organizations/-`. As all the parents
+// of a resource have the same pattern, `%` cannot match across segments.
+func parentLike(parent string) string {
+	segments := strings.Split(parent, "/")
+	for i, segment := range segments {
+		if segment == resourcename.Wildcard {
+			segments[i] = "%"
+		} else {
+			segments[i] = likeEscaper.Replace(segment)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// listChecksum identifies the parameters of a List call other than the page
+// size, as a page token must not be reused with different parameters.
+func listChecksum(opts ListOptions) uint32 {
+	h := fnv.New32a()
+	for _, s := range []string{opts.Parent, opts.Filter, opts.OrderBy, opts.Query, strconv.Itoa(int(opts.Skip))} {
+		h.Write([]byte(s))
+		h.Write([]byte{0})
+	}
+	return h.Sum32()
+}
+
+func encodePageToken(offset int, checksum uint32) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(fmt.Sprintf("%d:%d", offset, checksum)))
+}
+
+func decodePageToken(token string, checksum uint32) (int, error) {
+	if token == "" {
+		return 0, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return 0, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	offsetPart, checksumPart, _ := strings.Cut(string(b), ":")
+	offset, err := strconv.Atoi(offsetPart)
+	if err != nil || offset < 0 {
+		return 0, status.Error(codes.InvalidArgument, "invalid page_token")
+	}
+
+	if checksumPart != strconv.FormatUint(uint64(checksum), 10) {
+		return 0, status.Error(codes.InvalidArgument, "page_token does not match the parent, filter and order_by of the request")
+	}
+
+	return offset, nil
+}
+
+func internal(err error) error {
+	return status.Error(codes.Internal, err.Error())
+}
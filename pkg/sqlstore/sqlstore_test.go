@@ -0,0 +1,337 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/internal/testpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+	_ "modernc.org/sqlite"
+)
+
+// newTestRepository returns a repository of Project resources stored in an
+// in-memory SQLite database.
+func newTestRepository(t *testing.T) *Repository[*testpb.Project] {
+	t.Helper()
+
+	db, err := sql.Open("sqlite", ":memory:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Every connection would open its own in-memory database.
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+
+	repo, err := NewRepository[*testpb.Project](db, SQLite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, stmt := range repo.Table().CreateStatements(SQLite) {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return repo
+}
+
+func assertCode(t *testing.T, err error, want codes.Code) {
+	t.Helper()
+	if got := status.Code(err); got != want {
+		t.Fatalf("got error %v, want code %s", err, want)
+	}
+}
+
+func TestCreateGet(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	created, err := repo.Create(ctx, &testpb.Project{
+		Name:        "organizations/acme/projects/p1",
+		DisplayName: "P1",
+		Tier:        testpb.Project_PREMIUM,
+		Tags:        []string{"a", "b"},
+		Labels:      map[string]string{"env": "prod"},
+		Spec:        &testpb.Project_Spec{DiskSize: 10},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetCreateTime() == nil || created.GetUpdateTime() == nil || created.GetEtag() == "" {
+		t.Fatalf("create_time, update_time and etag not set: %v", created)
+	}
+
+	got, err := repo.Get(ctx, created.GetName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The database keeps the timestamps at the microsecond.
+	got.CreateTime, got.UpdateTime = created.CreateTime, created.UpdateTime
+	if !proto.Equal(got, created) {
+		t.Errorf("Get() = %v, want %v", got, created)
+	}
+
+	_, err = repo.Create(ctx, &testpb.Project{Name: created.GetName()})
+	assertCode(t, err, codes.AlreadyExists)
+
+	_, err = repo.Get(ctx, "organizations/acme/projects/missing")
+	assertCode(t, err, codes.NotFound)
+}
+
+func TestCreateUint64(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	res, err := repo.Create(ctx, &testpb.Project{Name: "organizations/acme/projects/max", Quota: math.MaxInt64})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, err := repo.Get(ctx, res.GetName()); err != nil || got.GetQuota() != math.MaxInt64 {
+		t.Errorf("Get() = %v, %v, want quota %d", got, err, uint64(math.MaxInt64))
+	}
+
+	_, err = repo.Create(ctx, &testpb.Project{Name: "organizations/acme/projects/overflow", Quota: math.MaxUint64})
+	assertCode(t, err, codes.InvalidArgument)
+}
+
+func TestUpdate(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	created, err := repo.Create(ctx, &testpb.Project{Name: "organizations/acme/projects/p1", DisplayName: "P1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stored, err := repo.Get(ctx, created.GetName())
+	if err != nil {
+		t.Fatal(err)
+	}
+	stored.DisplayName = "P2"
+	updated, err := repo.Update(ctx, stored)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.GetDisplayName() != "P2" || updated.GetEtag() == created.GetEtag() {
+		t.Errorf("Update() = %v, want the new display name and etag", updated)
+	}
+	if !proto.Equal(updated.GetCreateTime(), stored.GetCreateTime()) {
+		t.Errorf("Update() changed create_time from %v to %v", stored.GetCreateTime(), updated.GetCreateTime())
+	}
+
+	// The etag read before the update is stale.
+	stored.DisplayName = "P3"
+	_, err = repo.Update(ctx, stored)
+	assertCode(t, err, codes.Aborted)
+
+	// An empty etag skips the check.
+	stored.Etag = ""
+	if _, err := repo.Update(ctx, stored); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = repo.Update(ctx, &testpb.Project{Name: "organizations/acme/projects/missing"})
+	assertCode(t, err, codes.NotFound)
+}
+
+func TestDelete(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	created, err := repo.Create(ctx, &testpb.Project{Name: "organizations/acme/projects/p1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertCode(t, repo.Delete(ctx, created.GetName(), "stale"), codes.Aborted)
+	if err := repo.Delete(ctx, created.GetName(), created.GetEtag()); err != nil {
+		t.Fatal(err)
+	}
+	_, err = repo.Get(ctx, created.GetName())
+	assertCode(t, err, codes.NotFound)
+	assertCode(t, repo.Delete(ctx, created.GetName(), ""), codes.NotFound)
+}
+
+func TestListPagination(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	for _, name := range []string{
+		"organizations/acme/projects/p1",
+		"organizations/acme/projects/p2",
+		"organizations/acme/projects/p3",
+		"organizations/acme/projects/p4",
+		"organizations/acme/projects/p5",
+		"organizations/other/projects/p6",
+	} {
+		if _, err := repo.Create(ctx, &testpb.Project{Name: name, Replicas: int32(len(name))}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list := func(opts ListOptions) []string {
+		t.Helper()
+
+		var names []string
+		for {
+			resources, next, err := repo.List(ctx, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var page []string
+			for _, res := range resources {
+				page = append(page, res.GetName())
+			}
+			names = append(names, fmt.Sprint(page))
+			if next == "" {
+				return names
+			}
+			opts.PageToken = next
+		}
+	}
+
+	tests := []struct {
+		opts ListOptions
+		want []string
+	}{
+		{
+			ListOptions{Parent: "organizations/acme", PageSize: 2},
+			[]string{
+				"[organizations/acme/projects/p1 organizations/acme/projects/p2]",
+				"[organizations/acme/projects/p3 organizations/acme/projects/p4]",
+				"[organizations/acme/projects/p5]",
+			},
+		},
+		{
+			ListOptions{Parent: "organizations/acme", PageSize: 3, OrderBy: "name desc"},
+			[]string{
+				"[organizations/acme/projects/p5 organizations/acme/projects/p4 organizations/acme/projects/p3]",
+				"[organizations/acme/projects/p2 organizations/acme/projects/p1]",
+			},
+		},
+		{
+			ListOptions{Parent: "organizations/-", PageSize: 5, Filter: `name = "*/p6" OR name = "*/p1"`},
+			[]string{"[organizations/acme/projects/p1 organizations/other/projects/p6]"},
+		},
+		{
+			ListOptions{Parent: "organizations/acme", PageSize: 2, Skip: 3},
+			[]string{"[organizations/acme/projects/p4 organizations/acme/projects/p5]"},
+		},
+		{
+			// The skip is resent along with the page tokens, and applied once.
+			ListOptions{Parent: "organizations/acme", PageSize: 1, Skip: 2},
+			[]string{
+				"[organizations/acme/projects/p3]",
+				"[organizations/acme/projects/p4]",
+				"[organizations/acme/projects/p5]",
+			},
+		},
+		{
+			ListOptions{Parent: "organizations/missing"},
+			[]string{"[]"},
+		},
+	}
+
+	for _, tt := range tests {
+		if got := list(tt.opts); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("List(%+v) = %v, want %v", tt.opts, got, tt.want)
+		}
+	}
+
+	_, next, err := repo.List(ctx, ListOptions{Parent: "organizations/acme", PageSize: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = repo.List(ctx, ListOptions{Parent: "organizations/acme", PageSize: 2, PageToken: next, Filter: "replicas > 0"})
+	assertCode(t, err, codes.InvalidArgument)
+	_, _, err = repo.List(ctx, ListOptions{Parent: "organizations/acme", PageSize: 2, PageToken: next, Skip: 1})
+	assertCode(t, err, codes.InvalidArgument)
+
+	n, err := repo.Count(ctx, ListOptions{Parent: "organizations/acme"})
+	if err != nil || n != 5 {
+		t.Errorf("Count() = %d, %v, want 5", n, err)
+	}
+}
+
+func TestPageToken(t *testing.T) {
+	checksum := listChecksum(ListOptions{Parent: "organizations/acme", Filter: "replicas > 1"})
+
+	offset, err := decodePageToken(encodePageToken(42, checksum), checksum)
+	if err != nil || offset != 42 {
+		t.Errorf("decodePageToken(encodePageToken(42)) = %d, %v, want 42", offset, err)
+	}
+
+	if offset, err := decodePageToken("", checksum); err != nil || offset != 0 {
+		t.Errorf(`decodePageToken("") = %d, %v, want 0`, offset, err)
+	}
+
+	other := listChecksum(ListOptions{Parent: "organizations/acme", Filter: "replicas > 2"})
+	for _, token := range []string{
+		encodePageToken(42, other),
+		encodePageToken(-1, checksum),
+		"not base64!",
+	} {
+		if _, err := decodePageToken(token, checksum); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("decodePageToken(%q) = %v, want InvalidArgument", token, err)
+		}
+	}
+}
+
+func TestInTx(t *testing.T) {
+	ctx := context.Background()
+	repo := newTestRepository(t)
+
+	var committed []string
+	errRollback := errors.New("rollback")
+	err := repo.InTx(ctx, func(ctx context.Context) error {
+		if _, err := repo.Create(ctx, &testpb.Project{Name: "organizations/acme/projects/p1"}); err != nil {
+			return err
+		}
+		repo.AfterCommit(ctx, func() { committed = append(committed, "p1") })
+		return errRollback
+	})
+	if !errors.Is(err, errRollback) {
+		t.Fatalf("InTx() = %v, want %v", err, errRollback)
+	}
+	_, err = repo.Get(ctx, "organizations/acme/projects/p1")
+	assertCode(t, err, codes.NotFound)
+
+	err = repo.InTx(ctx, func(ctx context.Context) error {
+		created, err := repo.Create(ctx, &testpb.Project{Name: "organizations/acme/projects/p2"})
+		if err != nil {
+			return err
+		}
+		// Nested calls join the transaction.
+		if err := repo.InTx(ctx, func(ctx context.Context) error {
+			return repo.Delete(ctx, created.GetName(), created.GetEtag())
+		}); err != nil {
+			return err
+		}
+		repo.AfterCommit(ctx, func() { committed = append(committed, "p2") })
+		if len(committed) > 0 {
+			t.Errorf("AfterCommit() called %v before the commit", committed)
+		}
+		_, err = repo.Create(ctx, &testpb.Project{Name: created.GetName()})
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := repo.Get(ctx, "organizations/acme/projects/p2"); err != nil {
+		t.Errorf("Get() = %v after the commit", err)
+	}
+	if fmt.Sprint(committed) != "[p2]" {
+		t.Errorf("AfterCommit() called %v, want [p2]", committed)
+	}
+
+	repo.AfterCommit(ctx, func() { committed = append(committed, "now") })
+	if fmt.Sprint(committed) != "[p2 now]" {
+		t.Errorf("AfterCommit() outside of a transaction called %v, want [p2 now]", committed)
+	}
+}
@@ -0,0 +1,178 @@
+package sqlstore
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/fieldbehavior"
+	"github.com/stoewer/go-strcase"
+	"google.golang.org/genproto/googleapis/api/annotations"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// ParentColumn is the name of the column holding the name of the parent of
+// the resource, for resources having a parent.
+const ParentColumn = "parent"
+
+type columnKind int
+
+const (
+	kindText columnKind = iota
+	kindBool
+	kindInteger
+	kindBigint
+	kindReal
+	kindDouble
+	kindBytes
+	kindEnum
+	kindTimestamp
+	kindJSON
+)
+
+// Column maps a field of the resource to a column of the table.
+type Column struct {
+	// Name of the column, which is the name of the field.
+	Name string
+	// Field of the resource stored in the column, nil for the parent column.
+	Field protoreflect.FieldDescriptor
+
+	kind columnKind
+}
+
+// Table describes the SQL table storing a resource, with a column per field of
+// the resource message except INPUT_ONLY fields. Scalars are stored in columns
+// of the matching type, enums by value name, google.protobuf.Timestamp as
+// timestamps and every other message, repeated or map field as JSON. Unsigned
+// 64-bit integers are stored as BIGINT, rejecting the values above
+// math.MaxInt64.
+type Table struct {
+	// Name of the table, the snake case plural of the resource.
+	Name string
+	// Columns of the table, the first being the resource name.
+	Columns []Column
+	// Whether the table has a ParentColumn, set when the resource pattern has a
+	// parent.
+	HasParent bool
+
+	message protoreflect.MessageDescriptor
+}
+
+// NewTable derives the table storing the resource described by md, which must
+// be annotated with a google.api.resource option and have a `name` field.
+func NewTable(md protoreflect.MessageDescriptor) (*Table, error) {
+	rd, _ := proto.GetExtension(md.Options(), annotations.E_Resource).(*annotations.ResourceDescriptor)
+	if rd == nil {
+		return nil, fmt.Errorf("message %s is not a resource", md.FullName())
+	}
+
+	plural := rd.GetPlural()
+	if plural == "" {
+		return nil, fmt.Errorf("resource %s has no plural", rd.GetType())
+	}
+
+	nameField := md.Fields().ByName("name")
+	if nameField == nil || nameField.Kind() != protoreflect.StringKind || nameField.Cardinality() == protoreflect.Repeated {
+		return nil, fmt.Errorf("resource %s has no string name field", rd.GetType())
+	}
+
+	t := &Table{
+		Name:    strcase.SnakeCase(plural),
+		message: md,
+	}
+
+	for _, pattern := range rd.GetPattern() {
+		if strings.Count(pattern, "/") > 1 {
+			t.HasParent = true
+		}
+	}
+
+	t.Columns = append(t.Columns, Column{Name: "name", Field: nameField, kind: kindText})
+	if t.HasParent {
+		t.Columns = append(t.Columns, Column{Name: ParentColumn, kind: kindText})
+	}
+
+	fields := md.Fields()
+	for i := 0; i < fields.Len(); i++ {
+		fd := fields.Get(i)
+		if fd == nameField || fieldbehavior.Has(fd, annotations.FieldBehavior_INPUT_ONLY) {
+			continue
+		}
+		t.Columns = append(t.Columns, Column{Name: string(fd.Name()), Field: fd, kind: fieldKind(fd)})
+	}
+
+	return t, nil
+}
+
+// Column returns the column with the given name, if any.
+func (t *Table) Column(name string) (Column, bool) {
+	for _, c := range t.Columns {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return Column{}, false
+}
+
+// CreateStatements returns the statements creating the table and its indexes.
+func (t *Table) CreateStatements(d Dialect) []string {
+	var defs []string
+	for _, c := range t.Columns {
+		def := c.Name + " " + d.columnType(c.kind)
+		switch {
+		case c.Name == "name":
+			def += " PRIMARY KEY"
+		case c.Field == nil:
+			def += " NOT NULL"
+		}
+		defs = append(defs, def)
+	}
+
+	stmts := []string{
+		fmt.Sprintf("CREATE TABLE %s (\n  %s\n)", t.Name, strings.Join(defs, ",\n  ")),
+	}
+	if t.HasParent {
+		stmts = append(stmts, fmt.Sprintf("CREATE INDEX %s_%s_idx ON %s (%s)", t.Name, ParentColumn, t.Name, ParentColumn))
+	}
+
+	return stmts
+}
+
+func (t *Table) columnNames() []string {
+	names := make([]string, len(t.Columns))
+	for i, c := range t.Columns {
+		names[i] = c.Name
+	}
+	return names
+}
+
+func fieldKind(fd protoreflect.FieldDescriptor) columnKind {
+	if fd.IsList() || fd.IsMap() {
+		return kindJSON
+	}
+
+	switch fd.Kind() {
+	case protoreflect.BoolKind:
+		return kindBool
+	case protoreflect.Int32Kind, protoreflect.Sint32Kind, protoreflect.Sfixed32Kind:
+		return kindInteger
+	case protoreflect.Int64Kind, protoreflect.Sint64Kind, protoreflect.Sfixed64Kind,
+		protoreflect.Uint32Kind, protoreflect.Fixed32Kind, protoreflect.Uint64Kind, protoreflect.Fixed64Kind:
+		return kindBigint
+	case protoreflect.FloatKind:
+		return kindReal
+	case protoreflect.DoubleKind:
+		return kindDouble
+	case protoreflect.BytesKind:
+		return kindBytes
+	case protoreflect.EnumKind:
+		return kindEnum
+	case protoreflect.MessageKind, protoreflect.GroupKind:
+		if fd.Message().FullName() == "google.protobuf.Timestamp" {
+			return kindTimestamp
+		}
+		return kindJSON
+	default:
+		return kindText
+	}
+}
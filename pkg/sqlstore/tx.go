@@ -0,0 +1,76 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+)
+
+// txKey is the context key of the transaction in progress on a database.
+type txKey struct {
+	db *sql.DB
+}
+
+// txState is a transaction in progress, along with the functions to call
+// once it is committed.
+type txState struct {
+	tx       *sql.Tx
+	onCommit []func()
+}
+
+// conn is the subset of sql.DB and sql.Tx used to run queries.
+type conn interface {
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// InTx calls fn with a context in which the repositories sharing the database
+// of r run their queries in a single transaction, committed when fn returns
+// nil and rolled back otherwise. Calls made within the transaction of another
+// InTx join it, so that several methods, or the repositories of parent and
+// child resources, can change the database atomically.
+func (r *Repository[T]) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{r.db}).(*txState); ok {
+		return fn(ctx)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return internal(err)
+	}
+	defer tx.Rollback()
+
+	state := &txState{tx: tx}
+	if err := fn(context.WithValue(ctx, txKey{r.db}, state)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return internal(err)
+	}
+
+	for _, f := range state.onCommit {
+		f()
+	}
+	return nil
+}
+
+// AfterCommit calls f once the transaction of ctx started by InTx on the
+// database of r is committed, or right away outside of a transaction. It is
+// not called if the transaction is rolled back, e.g. to only notify the
+// changes which took effect.
+func (r *Repository[T]) AfterCommit(ctx context.Context, f func()) {
+	if state, ok := ctx.Value(txKey{r.db}).(*txState); ok {
+		state.onCommit = append(state.onCommit, f)
+		return
+	}
+	f()
+}
+
+// conn returns the transaction of ctx on the database of r, if any, or the
+// database itself.
+func (r *Repository[T]) conn(ctx context.Context) conn {
+	if state, ok := ctx.Value(txKey{r.db}).(*txState); ok {
+		return state.tx
+	}
+	return r.db
+}
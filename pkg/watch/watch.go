@@ -0,0 +1,161 @@
+// Package watch broadcasts the changes of resources to the server-streaming
+// Watch methods, which clients use instead of polling List.
+package watch
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// EventType is the type of a change. Its values match those of the Type enum
+// of the generated event messages.
+type EventType int32
+
+const (
+	Added    EventType = 1
+	Modified EventType = 2
+	Deleted  EventType = 3
+)
+
+// Event is a change of a resource.
+type Event[T proto.Message] struct {
+	Type EventType
+	// Resource is the resource after the change, or its last value for Deleted
+	// events.
+	Resource T
+	// ResumeToken identifies the event, resuming a watch after it. It is only
+	// valid for the hub which published the event.
+	ResumeToken string
+}
+
+// Hub broadcasts the changes of resources of type T to their watchers. It keeps
+// the most recent events so that interrupted watches can resume without
+// missing any of them. It is safe for concurrent use.
+type Hub[T proto.Message] struct {
+	historySize int
+	// epoch identifies the hub in the resume tokens, so that the tokens of
+	// another hub, e.g. of another replica or before a restart, are rejected
+	// instead of resuming from the wrong event.
+	epoch string
+
+	mu      sync.Mutex
+	seq     uint64
+	history []Event[T]
+	// changed is closed and replaced on every published event.
+	changed chan struct{}
+}
+
+// NewHub returns a hub keeping the last historySize events for resumption,
+// which must be positive.
+func NewHub[T proto.Message](historySize int) (*Hub[T], error) {
+	if historySize <= 0 {
+		return nil, fmt.Errorf("invalid history size %d, must be positive", historySize)
+	}
+
+	epoch := make([]byte, 8)
+	if _, err := rand.Read(epoch); err != nil {
+		return nil, fmt.Errorf("failed to generate the hub epoch: %v", err)
+	}
+
+	return &Hub[T]{
+		historySize: historySize,
+		epoch:       hex.EncodeToString(epoch),
+		changed:     make(chan struct{}),
+	}, nil
+}
+
+// Publish broadcasts a change of res to the watchers.
+func (h *Hub[T]) Publish(typ EventType, res T) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.seq++
+	h.history = append(h.history, Event[T]{
+		Type:        typ,
+		Resource:    proto.Clone(res).(T),
+		ResumeToken: h.epoch + "-" + strconv.FormatUint(h.seq, 10),
+	})
+	if len(h.history) > h.historySize {
+		h.history = h.history[len(h.history)-h.historySize:]
+	}
+
+	close(h.changed)
+	h.changed = make(chan struct{})
+}
+
+// Watch calls send with the events of the resources for which match returns
+// true, until ctx is done or send fails. Without a resume token only the
+// events published from now on are sent, while with the token of a previous
+// event those published after it are sent first. Resuming from an event that
+// is no longer kept, or from the token of another hub, fails with OutOfRange,
+// in which case the caller must List the resources again before watching them.
+func (h *Hub[T]) Watch(ctx context.Context, resumeToken string, match func(T) bool, send func(Event[T]) error) error {
+	h.mu.Lock()
+	next := h.seq + 1
+	if resumeToken != "" {
+		epoch, seqPart, _ := strings.Cut(resumeToken, "-")
+		seq, err := strconv.ParseUint(seqPart, 10, 64)
+		if err != nil {
+			h.mu.Unlock()
+			return status.Errorf(codes.InvalidArgument, "invalid resume_token %q", resumeToken)
+		}
+		if epoch != h.epoch {
+			h.mu.Unlock()
+			return status.Errorf(codes.OutOfRange, "resume_token %q was issued by another server", resumeToken)
+		}
+		if seq > h.seq {
+			h.mu.Unlock()
+			return status.Errorf(codes.InvalidArgument, "invalid resume_token %q", resumeToken)
+		}
+		if seq+1 < h.first() {
+			h.mu.Unlock()
+			return status.Errorf(codes.OutOfRange, "resume_token %q has expired", resumeToken)
+		}
+		next = seq + 1
+	}
+	h.mu.Unlock()
+
+	for {
+		h.mu.Lock()
+		if next < h.first() {
+			// The watcher was too slow to keep up with the events.
+			h.mu.Unlock()
+			return status.Error(codes.OutOfRange, "the watch fell behind the history of events")
+		}
+		events := append([]Event[T](nil), h.history[len(h.history)-int(h.seq+1-next):]...)
+		next = h.seq + 1
+		changed := h.changed
+		h.mu.Unlock()
+
+		for _, e := range events {
+			if !match(e.Resource) {
+				continue
+			}
+			e.Resource = proto.Clone(e.Resource).(T)
+			if err := send(e); err != nil {
+				return err
+			}
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		}
+	}
+}
+
+// first returns the sequence number of the oldest event kept. It must be
+// called with the lock held.
+func (h *Hub[T]) first() uint64 {
+	return h.seq + 1 - uint64(len(h.history))
+}
@@ -0,0 +1,154 @@
+package watch
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/fsaintjacques/aip-resource-proto-gen/pkg/internal/testpb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+var errDone = errors.New("done")
+
+func newTestHub(t *testing.T, historySize int) *Hub[*testpb.Project] {
+	t.Helper()
+
+	h, err := NewHub[*testpb.Project](historySize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return h
+}
+
+func project(name string) *testpb.Project {
+	return &testpb.Project{Name: "organizations/acme/projects/" + name}
+}
+
+// watch returns the n first events sent by h.Watch.
+func watch(t *testing.T, h *Hub[*testpb.Project], resumeToken string, n int) ([]Event[*testpb.Project], error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	var events []Event[*testpb.Project]
+	err := h.Watch(ctx, resumeToken, func(*testpb.Project) bool { return true }, func(e Event[*testpb.Project]) error {
+		events = append(events, e)
+		if len(events) == n {
+			return errDone
+		}
+		return nil
+	})
+	if errors.Is(err, errDone) {
+		err = nil
+	}
+
+	return events, err
+}
+
+func TestNewHub(t *testing.T) {
+	for _, size := range []int{0, -1} {
+		if _, err := NewHub[*testpb.Project](size); err == nil {
+			t.Errorf("NewHub(%d) succeeded, want an error", size)
+		}
+	}
+}
+
+func TestWatch(t *testing.T) {
+	h := newTestHub(t, 10)
+
+	h.Publish(Added, project("p1"))
+
+	// Without a resume token, only the events published from now on are sent,
+	// so keep publishing until the watch has started.
+	done := make(chan []Event[*testpb.Project])
+	go func() {
+		events, err := watch(t, h, "", 2)
+		if err != nil {
+			t.Error(err)
+		}
+		done <- events
+	}()
+
+	var events []Event[*testpb.Project]
+	for events == nil {
+		select {
+		case events = <-done:
+		case <-time.After(5 * time.Millisecond):
+			h.Publish(Modified, project("p1"))
+		}
+	}
+	if len(events) != 2 || events[0].Type != Modified || events[1].Type != Modified {
+		t.Fatalf("Watch() sent %v, want Modified events", events)
+	}
+
+	// Resuming sends the events published after the token.
+	resumed, err := watch(t, h, events[0].ResumeToken, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(resumed) != 1 || resumed[0].ResumeToken != events[1].ResumeToken {
+		t.Errorf("Watch(%q) sent %v, want %v", events[0].ResumeToken, resumed, events[1:])
+	}
+}
+
+func TestWatchMatch(t *testing.T) {
+	h := newTestHub(t, 10)
+
+	h.Publish(Added, project("p1"))
+	first := h.history[0].ResumeToken
+	h.Publish(Added, project("p2"))
+	h.Publish(Added, project("p3"))
+
+	var names []string
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	err := h.Watch(ctx, first, func(p *testpb.Project) bool { return p.GetName() != project("p2").GetName() }, func(e Event[*testpb.Project]) error {
+		names = append(names, e.Resource.GetName())
+		return errDone
+	})
+	if !errors.Is(err, errDone) || len(names) != 1 || names[0] != project("p3").GetName() {
+		t.Errorf("Watch() = %v, sent %v, want only p3", err, names)
+	}
+}
+
+func TestWatchResumeToken(t *testing.T) {
+	h := newTestHub(t, 2)
+	for _, name := range []string{"p1", "p2", "p3", "p4"} {
+		h.Publish(Added, project(name))
+	}
+
+	other := newTestHub(t, 2)
+	other.Publish(Added, project("p1"))
+
+	tests := []struct {
+		name  string
+		token string
+		want  codes.Code
+	}{
+		{"expired", h.epoch + "-1", codes.OutOfRange},
+		{"other hub", other.history[0].ResumeToken, codes.OutOfRange},
+		{"restarted hub", "0123456789abcdef-2", codes.OutOfRange},
+		{"future", h.epoch + "-5", codes.InvalidArgument},
+		{"malformed", "bogus", codes.InvalidArgument},
+		{"bare sequence", "2", codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := watch(t, h, tt.token, 1)
+			if got := status.Code(err); got != tt.want {
+				t.Errorf("Watch(%q) = %v, want %s", tt.token, err, tt.want)
+			}
+		})
+	}
+
+	// The oldest kept event is still resumable.
+	events, err := watch(t, h, h.epoch+"-2", 1)
+	if err != nil || len(events) != 1 || events[0].Resource.GetName() != project("p3").GetName() {
+		t.Errorf("Watch() = %v, %v, want p3", events, err)
+	}
+}